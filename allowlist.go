@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+const (
+	// allowlistDescriptionPrefix marks the entries owned by the sidecar, followed
+	// by the cluster and the id of the task (cluster/taskId).
+	allowlistDescriptionPrefix = "ecs-sidecar task "
+
+	allowlistMaxAttempts = 5
+	allowlistRetryDelay  = time.Second
+
+	prefixListVersionMismatchErrorCode = "PrefixListVersionMismatch"
+	incorrectStateErrorCode            = "IncorrectState"
+	duplicatePermissionErrorCode       = "InvalidPermission.Duplicate"
+	permissionNotFoundErrorCode        = "InvalidPermission.NotFound"
+
+	gcReasonTaskNotRunning = "task %v is not running"
+)
+
+type allowlistEntry struct {
+	Cidr    string
+	Cluster string
+	TaskId  string
+}
+
+func newAllowlistEntry(ip string, clusterName string, taskId string) allowlistEntry {
+	return allowlistEntry{Cidr: ip + "/32", Cluster: clusterKey(clusterName), TaskId: taskId}
+}
+
+func (e allowlistEntry) description() string {
+	return allowlistDescriptionPrefix + e.Cluster + "/" + e.TaskId
+}
+
+// parseAllowlistEntry returns false when the description isn't one of the
+// sidecar. Entries written before the cluster was part of the description
+// have no cluster.
+func parseAllowlistEntry(cidr string, description string) (allowlistEntry, bool) {
+	if !strings.HasPrefix(description, allowlistDescriptionPrefix) {
+		return allowlistEntry{}, false
+	}
+
+	owner := strings.TrimPrefix(description, allowlistDescriptionPrefix)
+
+	parts := strings.SplitN(owner, "/", 2)
+	if len(parts) == 1 {
+		return allowlistEntry{Cidr: cidr, TaskId: owner}, true
+	}
+
+	return allowlistEntry{Cidr: cidr, Cluster: parts[0], TaskId: parts[1]}, true
+}
+
+// Allowlist keeps the public ips of the running tasks in a list trusted by
+// another service. The same ip can move to another task, so Add takes over an
+// existing entry and Remove leaves it alone when another task holds it.
+type Allowlist interface {
+	Add(ctx context.Context, entry allowlistEntry) error
+	Remove(ctx context.Context, entry allowlistEntry) error
+	// Entries returns only the entries added by the sidecar.
+	Entries(ctx context.Context) ([]allowlistEntry, error)
+	String() string
+}
+
+// newAllowlist receives a managed prefix list id (pl-...) or a security group
+// id with an optional protocol and port range (sg-...:tcp:443).
+func newAllowlist(ec2Api Ec2Api, spec string) (Allowlist, error) {
+	parts := strings.Split(spec, ":")
+
+	switch {
+	case strings.HasPrefix(parts[0], "pl-") && len(parts) == 1:
+		return NewPrefixListAllowlist(ec2Api, parts[0]), nil
+	case strings.HasPrefix(parts[0], "sg-") && len(parts) == 1:
+		return NewSecurityGroupAllowlist(ec2Api, parts[0], "-1", 0, 0), nil
+	case strings.HasPrefix(parts[0], "sg-") && len(parts) == 3:
+		ports := strings.SplitN(parts[2], "-", 2)
+
+		fromPort, err := strconv.Atoi(ports[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in allowlist '%v'", spec)
+		}
+
+		toPort := fromPort
+		if len(ports) == 2 {
+			toPort, err = strconv.Atoi(ports[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid port in allowlist '%v'", spec)
+			}
+		}
+
+		return NewSecurityGroupAllowlist(ec2Api, parts[0], parts[1], int32(fromPort), int32(toPort)), nil
+	}
+
+	return nil, fmt.Errorf("invalid allowlist '%v', expected pl-id, sg-id or sg-id:protocol:port", spec)
+}
+
+// PrefixListAllowlist keeps the ips in a managed prefix list. Every change
+// names the version it is based on, so concurrent changes made by other tasks
+// are detected and retried instead of overwritten.
+type PrefixListAllowlist struct {
+	ec2Api       Ec2Api
+	prefixListId string
+	sleep        func(ctx context.Context, d time.Duration) error
+}
+
+func NewPrefixListAllowlist(ec2Api Ec2Api, prefixListId string) *PrefixListAllowlist {
+	return &PrefixListAllowlist{ec2Api: ec2Api, prefixListId: prefixListId, sleep: sleepContext}
+}
+
+func (a *PrefixListAllowlist) String() string {
+	return a.prefixListId
+}
+
+// Add adds the entry, or replaces the description of the cidr when it is
+// already in the list, as adding an existing cidr replaces its description.
+func (a *PrefixListAllowlist) Add(ctx context.Context, entry allowlistEntry) error {
+	return a.modify(ctx, entry.Cidr, func(current *ec2Types.PrefixListEntry, input *ec2.ModifyManagedPrefixListInput) bool {
+		input.AddEntries = []ec2Types.AddPrefixListEntry{{Cidr: aws.String(entry.Cidr), Description: aws.String(entry.description())}}
+
+		return current == nil || aws.ToString(current.Description) != entry.description()
+	})
+}
+
+func (a *PrefixListAllowlist) Remove(ctx context.Context, entry allowlistEntry) error {
+	return a.modify(ctx, entry.Cidr, func(current *ec2Types.PrefixListEntry, input *ec2.ModifyManagedPrefixListInput) bool {
+		input.RemoveEntries = []ec2Types.RemovePrefixListEntry{{Cidr: aws.String(entry.Cidr)}}
+
+		return current != nil && aws.ToString(current.Description) == entry.description()
+	})
+}
+
+// modify reads the current version and entries of the list and applies the
+// change, when the change function says it's needed, until no other change
+// gets in between.
+func (a *PrefixListAllowlist) modify(ctx context.Context, cidr string, change func(current *ec2Types.PrefixListEntry, input *ec2.ModifyManagedPrefixListInput) bool) error {
+	for attempt := 1; ; attempt++ {
+		version, entries, err := a.getEntries(ctx)
+		if err != nil {
+			return err
+		}
+
+		var current *ec2Types.PrefixListEntry
+		for i := range entries {
+			if aws.ToString(entries[i].Cidr) == cidr {
+				current = &entries[i]
+			}
+		}
+
+		input := &ec2.ModifyManagedPrefixListInput{
+			PrefixListId:   aws.String(a.prefixListId),
+			CurrentVersion: aws.Int64(version),
+		}
+
+		if !change(current, input) {
+			return nil
+		}
+
+		_, err = a.ec2Api.ModifyManagedPrefixList(ctx, input)
+		if err == nil {
+			log.Printf("Modified prefix list '%v' for '%v'\n", a.prefixListId, cidr)
+
+			return nil
+		}
+
+		code := awsErrorCode(err)
+		if (code != prefixListVersionMismatchErrorCode && code != incorrectStateErrorCode) || attempt >= allowlistMaxAttempts {
+			return fmt.Errorf("error modifying the prefix list '%v': %v", a.prefixListId, err)
+		}
+
+		err = a.sleep(ctx, allowlistRetryDelay*time.Duration(attempt))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (a *PrefixListAllowlist) getEntries(ctx context.Context) (int64, []ec2Types.PrefixListEntry, error) {
+	describeManagedPrefixListsOutput, err := a.ec2Api.DescribeManagedPrefixLists(ctx, &ec2.DescribeManagedPrefixListsInput{
+		PrefixListIds: []string{a.prefixListId},
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("error describing the prefix list '%v': %v", a.prefixListId, err)
+	}
+
+	if len(describeManagedPrefixListsOutput.PrefixLists) == 0 {
+		return 0, nil, fmt.Errorf("prefix list '%v' not found", a.prefixListId)
+	}
+
+	version := aws.ToInt64(describeManagedPrefixListsOutput.PrefixLists[0].Version)
+
+	entries := []ec2Types.PrefixListEntry{}
+
+	input := &ec2.GetManagedPrefixListEntriesInput{
+		PrefixListId:  aws.String(a.prefixListId),
+		TargetVersion: aws.Int64(version),
+	}
+
+	for {
+		getManagedPrefixListEntriesOutput, err := a.ec2Api.GetManagedPrefixListEntries(ctx, input)
+		if err != nil {
+			return 0, nil, fmt.Errorf("error getting the entries of the prefix list '%v': %v", a.prefixListId, err)
+		}
+
+		entries = append(entries, getManagedPrefixListEntriesOutput.Entries...)
+
+		if getManagedPrefixListEntriesOutput.NextToken == nil {
+			return version, entries, nil
+		}
+
+		input.NextToken = getManagedPrefixListEntriesOutput.NextToken
+	}
+}
+
+func (a *PrefixListAllowlist) Entries(ctx context.Context) ([]allowlistEntry, error) {
+	_, entries, err := a.getEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []allowlistEntry{}
+	for _, entry := range entries {
+		allowlistEntry, ok := parseAllowlistEntry(aws.ToString(entry.Cidr), aws.ToString(entry.Description))
+		if ok {
+			result = append(result, allowlistEntry)
+		}
+	}
+
+	return result, nil
+}
+
+// SecurityGroupAllowlist keeps the ips as ingress rules of a security group.
+type SecurityGroupAllowlist struct {
+	ec2Api   Ec2Api
+	groupId  string
+	protocol string
+	fromPort int32
+	toPort   int32
+}
+
+func NewSecurityGroupAllowlist(ec2Api Ec2Api, groupId string, protocol string, fromPort int32, toPort int32) *SecurityGroupAllowlist {
+	return &SecurityGroupAllowlist{ec2Api: ec2Api, groupId: groupId, protocol: protocol, fromPort: fromPort, toPort: toPort}
+}
+
+func (a *SecurityGroupAllowlist) String() string {
+	return a.groupId
+}
+
+func (a *SecurityGroupAllowlist) permission(ipRange ec2Types.IpRange) ec2Types.IpPermission {
+	permission := ec2Types.IpPermission{
+		IpProtocol: aws.String(a.protocol),
+		IpRanges:   []ec2Types.IpRange{ipRange},
+	}
+
+	if a.protocol != "-1" {
+		permission.FromPort = aws.Int32(a.fromPort)
+		permission.ToPort = aws.Int32(a.toPort)
+	}
+
+	return permission
+}
+
+// Add authorizes the entry, or replaces the description of the rule when the
+// cidr is already authorized.
+func (a *SecurityGroupAllowlist) Add(ctx context.Context, entry allowlistEntry) error {
+	input := &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(a.groupId),
+		IpPermissions: []ec2Types.IpPermission{a.permission(ec2Types.IpRange{CidrIp: aws.String(entry.Cidr), Description: aws.String(entry.description())})},
+	}
+
+	_, err := a.ec2Api.AuthorizeSecurityGroupIngress(ctx, input)
+	if err != nil && awsErrorCode(err) != duplicatePermissionErrorCode {
+		return fmt.Errorf("error authorizing '%v' in security group '%v': %v", entry.Cidr, a.groupId, err)
+	}
+
+	if err != nil {
+		rule, err := a.findRule(ctx, entry.Cidr)
+		if err != nil {
+			return err
+		}
+
+		if rule != nil && aws.ToString(rule.Description) != entry.description() {
+			_, err = a.ec2Api.UpdateSecurityGroupRuleDescriptionsIngress(ctx, &ec2.UpdateSecurityGroupRuleDescriptionsIngressInput{
+				GroupId: aws.String(a.groupId),
+				SecurityGroupRuleDescriptions: []ec2Types.SecurityGroupRuleDescription{
+					{SecurityGroupRuleId: rule.SecurityGroupRuleId, Description: aws.String(entry.description())},
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("error updating the rule of '%v' in security group '%v': %v", entry.Cidr, a.groupId, err)
+			}
+		}
+	}
+
+	log.Printf("Authorized '%v' in security group '%v'\n", entry.Cidr, a.groupId)
+
+	return nil
+}
+
+// Remove revokes the rule of the entry only while it still names the task.
+func (a *SecurityGroupAllowlist) Remove(ctx context.Context, entry allowlistEntry) error {
+	rule, err := a.findRule(ctx, entry.Cidr)
+	if err != nil {
+		return err
+	}
+
+	if rule == nil || aws.ToString(rule.Description) != entry.description() {
+		return nil
+	}
+
+	input := &ec2.RevokeSecurityGroupIngressInput{
+		GroupId:              aws.String(a.groupId),
+		SecurityGroupRuleIds: []string{aws.ToString(rule.SecurityGroupRuleId)},
+	}
+
+	_, err = a.ec2Api.RevokeSecurityGroupIngress(ctx, input)
+	if err != nil && awsErrorCode(err) != permissionNotFoundErrorCode {
+		return fmt.Errorf("error revoking '%v' in security group '%v': %v", entry.Cidr, a.groupId, err)
+	}
+
+	log.Printf("Revoked '%v' in security group '%v'\n", entry.Cidr, a.groupId)
+
+	return nil
+}
+
+// findRule returns the ingress rule of the cidr with the protocol and ports of
+// the allowlist, or nil when there is none.
+func (a *SecurityGroupAllowlist) findRule(ctx context.Context, cidr string) (*ec2Types.SecurityGroupRule, error) {
+	rules, err := a.getRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, rule := range rules {
+		if aws.ToString(rule.CidrIpv4) != cidr || aws.ToString(rule.IpProtocol) != a.protocol {
+			continue
+		}
+
+		if a.protocol != "-1" && (aws.ToInt32(rule.FromPort) != a.fromPort || aws.ToInt32(rule.ToPort) != a.toPort) {
+			continue
+		}
+
+		return &rules[i], nil
+	}
+
+	return nil, nil
+}
+
+// getRules returns the ingress rules of the group.
+func (a *SecurityGroupAllowlist) getRules(ctx context.Context) ([]ec2Types.SecurityGroupRule, error) {
+	result := []ec2Types.SecurityGroupRule{}
+
+	input := &ec2.DescribeSecurityGroupRulesInput{
+		Filters: []ec2Types.Filter{{Name: aws.String("group-id"), Values: []string{a.groupId}}},
+	}
+
+	for {
+		describeSecurityGroupRulesOutput, err := a.ec2Api.DescribeSecurityGroupRules(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("error describing the rules of security group '%v': %v", a.groupId, err)
+		}
+
+		for _, rule := range describeSecurityGroupRulesOutput.SecurityGroupRules {
+			if !aws.ToBool(rule.IsEgress) && rule.CidrIpv4 != nil {
+				result = append(result, rule)
+			}
+		}
+
+		if describeSecurityGroupRulesOutput.NextToken == nil {
+			return result, nil
+		}
+
+		input.NextToken = describeSecurityGroupRulesOutput.NextToken
+	}
+}
+
+func (a *SecurityGroupAllowlist) Entries(ctx context.Context) ([]allowlistEntry, error) {
+	rules, err := a.getRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []allowlistEntry{}
+	for _, rule := range rules {
+		entry, ok := parseAllowlistEntry(aws.ToString(rule.CidrIpv4), aws.ToString(rule.Description))
+		if ok {
+			result = append(result, entry)
+		}
+	}
+
+	return result, nil
+}
+
+// collectAllowlist removes the entries of the tasks of the cluster that are no
+// longer running. The entries of other clusters sharing the allowlist are left
+// to their own collector, as are the entries without a cluster.
+func collectAllowlist(ctx context.Context, ecsApi EcsApi, clusterName string, allowlist Allowlist, dryRun bool) ([]gcReportEntry, error) {
+	entries, err := allowlist.Entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := getServiceTasks(ctx, ecsApi, clusterName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	runningTaskIds := map[string]bool{}
+	for _, task := range tasks {
+		runningTaskIds[taskIdFromArn(aws.ToString(task.TaskArn))] = true
+	}
+
+	report := []gcReportEntry{}
+	for _, entry := range entries {
+		if entry.Cluster != clusterKey(clusterName) || runningTaskIds[entry.TaskId] {
+			continue
+		}
+
+		action := gcActionWouldRemove
+		if !dryRun {
+			err = allowlist.Remove(ctx, entry)
+			if err != nil {
+				return report, err
+			}
+
+			action = gcActionRemoved
+		}
+
+		report = append(report, gcReportEntry{Record: allowlist.String(), Ip: entry.Cidr, Action: action, Reason: fmt.Sprintf(gcReasonTaskNotRunning, entry.TaskId)})
+	}
+
+	return report, nil
+}
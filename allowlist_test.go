@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+var testAllowlistEntry = newAllowlistEntry("1.1.1.1", "arn:aws:ecs:eu-west-1:111122223333:cluster/cluster", "a1b2c3d4")
+
+func newTestPrefixListAllowlist(ec2Api Ec2Api) *PrefixListAllowlist {
+	allowlist := NewPrefixListAllowlist(ec2Api, "pl-1")
+	allowlist.sleep = func(ctx context.Context, d time.Duration) error {
+		return nil
+	}
+
+	return allowlist
+}
+
+func describePrefixList(mockedEc2Api *MockedEc2Api, version int64, entries ...ec2Types.PrefixListEntry) {
+	describeOutput := &ec2.DescribeManagedPrefixListsOutput{PrefixLists: []ec2Types.ManagedPrefixList{{Version: aws.Int64(version)}}}
+	mockedEc2Api.On("DescribeManagedPrefixLists", context.TODO(), &ec2.DescribeManagedPrefixListsInput{PrefixListIds: []string{"pl-1"}}).Return(describeOutput, nil).Once()
+
+	entriesInput := &ec2.GetManagedPrefixListEntriesInput{PrefixListId: aws.String("pl-1"), TargetVersion: aws.Int64(version)}
+	mockedEc2Api.On("GetManagedPrefixListEntries", context.TODO(), entriesInput).Return(&ec2.GetManagedPrefixListEntriesOutput{Entries: entries}, nil).Once()
+}
+
+func addPrefixListEntryInput(version int64) *ec2.ModifyManagedPrefixListInput {
+	return &ec2.ModifyManagedPrefixListInput{
+		PrefixListId:   aws.String("pl-1"),
+		CurrentVersion: aws.Int64(version),
+		AddEntries:     []ec2Types.AddPrefixListEntry{{Cidr: aws.String("1.1.1.1/32"), Description: aws.String("ecs-sidecar task cluster/a1b2c3d4")}},
+	}
+}
+
+func Test_PrefixListAllowlist_Add(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePrefixList(mockedEc2Api, 3)
+	mockedEc2Api.On("ModifyManagedPrefixList", context.TODO(), addPrefixListEntryInput(3)).Return(&ec2.ModifyManagedPrefixListOutput{}, nil).Once()
+
+	err := newTestPrefixListAllowlist(mockedEc2Api).Add(context.TODO(), testAllowlistEntry)
+
+	assert.Nil(t, err)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_PrefixListAllowlist_Add_RetriesVersionMismatch(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	versionMismatch := &smithy.GenericAPIError{Code: "PrefixListVersionMismatch", Message: "version mismatch"}
+
+	describePrefixList(mockedEc2Api, 3)
+	mockedEc2Api.On("ModifyManagedPrefixList", context.TODO(), addPrefixListEntryInput(3)).Return(nil, versionMismatch).Once()
+	describePrefixList(mockedEc2Api, 4, ec2Types.PrefixListEntry{Cidr: aws.String("2.2.2.2/32"), Description: aws.String("ecs-sidecar task other")})
+	mockedEc2Api.On("ModifyManagedPrefixList", context.TODO(), addPrefixListEntryInput(4)).Return(&ec2.ModifyManagedPrefixListOutput{}, nil).Once()
+
+	err := newTestPrefixListAllowlist(mockedEc2Api).Add(context.TODO(), testAllowlistEntry)
+
+	assert.Nil(t, err)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_PrefixListAllowlist_Add_AlreadyPresent(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePrefixList(mockedEc2Api, 3, ec2Types.PrefixListEntry{Cidr: aws.String("1.1.1.1/32"), Description: aws.String("ecs-sidecar task cluster/a1b2c3d4")})
+
+	err := newTestPrefixListAllowlist(mockedEc2Api).Add(context.TODO(), testAllowlistEntry)
+
+	assert.Nil(t, err)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_PrefixListAllowlist_Add_ReplacesPreviousTask(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePrefixList(mockedEc2Api, 3, ec2Types.PrefixListEntry{Cidr: aws.String("1.1.1.1/32"), Description: aws.String("ecs-sidecar task cluster/stopped")})
+	mockedEc2Api.On("ModifyManagedPrefixList", context.TODO(), addPrefixListEntryInput(3)).Return(&ec2.ModifyManagedPrefixListOutput{}, nil).Once()
+
+	err := newTestPrefixListAllowlist(mockedEc2Api).Add(context.TODO(), testAllowlistEntry)
+
+	assert.Nil(t, err)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_PrefixListAllowlist_Remove(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePrefixList(mockedEc2Api, 3, ec2Types.PrefixListEntry{Cidr: aws.String("1.1.1.1/32"), Description: aws.String("ecs-sidecar task cluster/a1b2c3d4")})
+	input := &ec2.ModifyManagedPrefixListInput{
+		PrefixListId:   aws.String("pl-1"),
+		CurrentVersion: aws.Int64(3),
+		RemoveEntries:  []ec2Types.RemovePrefixListEntry{{Cidr: aws.String("1.1.1.1/32")}},
+	}
+	mockedEc2Api.On("ModifyManagedPrefixList", context.TODO(), input).Return(&ec2.ModifyManagedPrefixListOutput{}, nil).Once()
+
+	err := newTestPrefixListAllowlist(mockedEc2Api).Remove(context.TODO(), testAllowlistEntry)
+
+	assert.Nil(t, err)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_PrefixListAllowlist_Remove_OtherTask(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePrefixList(mockedEc2Api, 3, ec2Types.PrefixListEntry{Cidr: aws.String("1.1.1.1/32"), Description: aws.String("ecs-sidecar task cluster/e5f6a7b8")})
+
+	err := newTestPrefixListAllowlist(mockedEc2Api).Remove(context.TODO(), testAllowlistEntry)
+
+	assert.Nil(t, err)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func describeSecurityGroupRule(mockedEc2Api *MockedEc2Api, description string) {
+	rulesInput := &ec2.DescribeSecurityGroupRulesInput{Filters: []ec2Types.Filter{{Name: aws.String("group-id"), Values: []string{"sg-1"}}}}
+	rulesOutput := &ec2.DescribeSecurityGroupRulesOutput{
+		SecurityGroupRules: []ec2Types.SecurityGroupRule{{
+			SecurityGroupRuleId: aws.String("sgr-1"),
+			IpProtocol:          aws.String("tcp"),
+			FromPort:            aws.Int32(443),
+			ToPort:              aws.Int32(443),
+			CidrIpv4:            aws.String("1.1.1.1/32"),
+			Description:         aws.String(description),
+		}},
+	}
+	mockedEc2Api.On("DescribeSecurityGroupRules", context.TODO(), rulesInput).Return(rulesOutput, nil).Once()
+}
+
+func Test_SecurityGroupAllowlist_AddAndRemove(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	authorizeInput := &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId: aws.String("sg-1"),
+		IpPermissions: []ec2Types.IpPermission{{
+			IpProtocol: aws.String("tcp"),
+			FromPort:   aws.Int32(443),
+			ToPort:     aws.Int32(443),
+			IpRanges:   []ec2Types.IpRange{{CidrIp: aws.String("1.1.1.1/32"), Description: aws.String("ecs-sidecar task cluster/a1b2c3d4")}},
+		}},
+	}
+	duplicate := &smithy.GenericAPIError{Code: "InvalidPermission.Duplicate", Message: "duplicate"}
+	mockedEc2Api.On("AuthorizeSecurityGroupIngress", context.TODO(), authorizeInput).Return(nil, duplicate).Once()
+
+	describeSecurityGroupRule(mockedEc2Api, "ecs-sidecar task cluster/stopped")
+	updateInput := &ec2.UpdateSecurityGroupRuleDescriptionsIngressInput{
+		GroupId:                       aws.String("sg-1"),
+		SecurityGroupRuleDescriptions: []ec2Types.SecurityGroupRuleDescription{{SecurityGroupRuleId: aws.String("sgr-1"), Description: aws.String("ecs-sidecar task cluster/a1b2c3d4")}},
+	}
+	mockedEc2Api.On("UpdateSecurityGroupRuleDescriptionsIngress", context.TODO(), updateInput).Return(&ec2.UpdateSecurityGroupRuleDescriptionsIngressOutput{}, nil).Once()
+
+	describeSecurityGroupRule(mockedEc2Api, "ecs-sidecar task cluster/a1b2c3d4")
+	revokeInput := &ec2.RevokeSecurityGroupIngressInput{GroupId: aws.String("sg-1"), SecurityGroupRuleIds: []string{"sgr-1"}}
+	mockedEc2Api.On("RevokeSecurityGroupIngress", context.TODO(), revokeInput).Return(&ec2.RevokeSecurityGroupIngressOutput{}, nil).Once()
+
+	allowlist, err := newAllowlist(mockedEc2Api, "sg-1:tcp:443")
+	assert.Nil(t, err)
+
+	err = allowlist.Add(context.TODO(), testAllowlistEntry)
+	assert.Nil(t, err)
+
+	err = allowlist.Remove(context.TODO(), testAllowlistEntry)
+	assert.Nil(t, err)
+
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_SecurityGroupAllowlist_Remove_OtherTask(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describeSecurityGroupRule(mockedEc2Api, "ecs-sidecar task cluster/e5f6a7b8")
+
+	err := NewSecurityGroupAllowlist(mockedEc2Api, "sg-1", "tcp", 443, 443).Remove(context.TODO(), testAllowlistEntry)
+
+	assert.Nil(t, err)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_NewAllowlist(t *testing.T) {
+	allowlist, err := newAllowlist(NewMockedEc2Api(), "pl-1")
+	assert.Nil(t, err)
+	assert.IsType(t, &PrefixListAllowlist{}, allowlist)
+
+	allowlist, err = newAllowlist(NewMockedEc2Api(), "sg-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "-1", allowlist.(*SecurityGroupAllowlist).protocol)
+
+	allowlist, err = newAllowlist(NewMockedEc2Api(), "sg-1:udp:7000-7100")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(7000), allowlist.(*SecurityGroupAllowlist).fromPort)
+	assert.Equal(t, int32(7100), allowlist.(*SecurityGroupAllowlist).toPort)
+
+	_, err = newAllowlist(NewMockedEc2Api(), "sg-1:tcp:https")
+	assert.EqualError(t, err, "invalid port in allowlist 'sg-1:tcp:https'")
+
+	_, err = newAllowlist(NewMockedEc2Api(), "vpc-1")
+	assert.EqualError(t, err, "invalid allowlist 'vpc-1', expected pl-id, sg-id or sg-id:protocol:port")
+}
+
+func Test_CollectAllowlist(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+	mockedEcsApi := NewMockedEcsApi()
+
+	rulesInput := &ec2.DescribeSecurityGroupRulesInput{Filters: []ec2Types.Filter{{Name: aws.String("group-id"), Values: []string{"sg-1"}}}}
+	rulesOutput := &ec2.DescribeSecurityGroupRulesOutput{
+		SecurityGroupRules: []ec2Types.SecurityGroupRule{
+			{CidrIpv4: aws.String("1.1.1.1/32"), Description: aws.String("ecs-sidecar task cluster/running")},
+			{CidrIpv4: aws.String("2.2.2.2/32"), Description: aws.String("ecs-sidecar task cluster/stopped")},
+			{CidrIpv4: aws.String("3.3.3.3/32"), Description: aws.String("office")},
+			{CidrIpv4: aws.String("4.4.4.4/32"), Description: aws.String("ecs-sidecar task other/stopped")},
+			{CidrIpv4: aws.String("5.5.5.5/32"), Description: aws.String("ecs-sidecar task stopped")},
+			{CidrIpv4: aws.String("0.0.0.0/0"), IsEgress: aws.Bool(true)},
+		},
+	}
+	mockedEc2Api.On("DescribeSecurityGroupRules", context.TODO(), rulesInput).Return(rulesOutput, nil).Once()
+
+	listTasksInput := &ecs.ListTasksInput{Cluster: aws.String("cluster"), DesiredStatus: ecsTypes.DesiredStatusRunning}
+	mockedEcsApi.On("ListTasks", context.TODO(), listTasksInput).Return(&ecs.ListTasksOutput{TaskArns: []string{"arn:aws:ecs:eu-west-1:111122223333:task/cluster/running"}}, nil).Once()
+	describeTasksInput := &ecs.DescribeTasksInput{Cluster: aws.String("cluster"), Tasks: []string{"arn:aws:ecs:eu-west-1:111122223333:task/cluster/running"}, Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags}}
+	describeTasksOutput := &ecs.DescribeTasksOutput{Tasks: []ecsTypes.Task{{TaskArn: aws.String("arn:aws:ecs:eu-west-1:111122223333:task/cluster/running")}}}
+	mockedEcsApi.On("DescribeTasks", context.TODO(), describeTasksInput).Return(describeTasksOutput, nil).Once()
+
+	report, err := collectAllowlist(context.TODO(), mockedEcsApi, "cluster", NewSecurityGroupAllowlist(mockedEc2Api, "sg-1", "-1", 0, 0), true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []gcReportEntry{{Record: "sg-1", Ip: "2.2.2.2/32", Action: gcActionWouldRemove, Reason: "task stopped is not running"}}, report)
+	mockedEc2Api.AssertExpectations(t)
+	mockedEcsApi.AssertExpectations(t)
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	controllerCommand = "controller"
+
+	describeTasksBatchSize             = 100
+	describeNetworkInterfacesBatchSize = 100
+
+	defaultControllerInterval = 30 * time.Second
+)
+
+type desiredRecord struct {
+	Settings  dnsSettings
+	PublicIps []string
+}
+
+// Controller manages the DNS records of every task of the selected services
+// of a cluster, so a single process can replace one sidecar per task. The
+// records it publishes get a controller owner record, and the owned records
+// of the zones it has seen that no longer have running tasks are deleted.
+type Controller struct {
+	ecsApi      EcsApi
+	ec2Api      Ec2Api
+	route53Api  Route53Api
+	clusterName string
+	services    []string
+	defaults    dnsSettings
+	zones       map[string]bool
+}
+
+func NewController(ecsApi EcsApi, ec2Api Ec2Api, route53Api Route53Api, clusterName string, services []string, defaults dnsSettings) *Controller {
+	controller := &Controller{
+		ecsApi:      ecsApi,
+		ec2Api:      ec2Api,
+		route53Api:  route53Api,
+		clusterName: clusterName,
+		services:    services,
+		defaults:    defaults,
+		zones:       map[string]bool{},
+	}
+
+	if len(defaults.HostedZoneId) > 0 {
+		controller.zones[defaults.HostedZoneId] = true
+	}
+
+	return controller
+}
+
+func runController(ctx context.Context, cfg aws.Config, clusterName string, defaults dnsSettings, args []string) {
+	flags := flag.NewFlagSet(controllerCommand, flag.ExitOnError)
+	services := flags.String("services", os.Getenv("SERVICES"), "comma separated list of the services to manage")
+	interval := flags.Duration("interval", defaultControllerInterval, "time between reconciliations")
+	flags.Parse(args)
+
+	if len(*services) == 0 {
+		log.Fatal("no services configured")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	route53Api := NewRetryingRoute53Api(InitRoute53Api(cfg), defaultRoute53RetryPolicy(), newTokenBucket(route53RequestsPerSecond, route53RequestsBurst))
+
+	controller := NewController(InitEcsApi(cfg), InitEc2Api(cfg), route53Api, clusterName, strings.Split(*services, ","), defaults)
+
+	controller.Run(ctx, *interval)
+}
+
+func (c *Controller) Run(ctx context.Context, interval time.Duration) {
+	for {
+		err := c.Reconcile(ctx)
+		if err != nil {
+			log.Printf("Error reconciling cluster '%v': %v\n", c.clusterName, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context) error {
+	records, err := c.getDesiredRecords(ctx)
+	if err != nil {
+		return err
+	}
+
+	owner := recordOwnerId(ownerKindController, c.clusterName)
+	desiredNames := map[string]bool{}
+
+	failed := 0
+	for _, record := range records {
+		settings := record.Settings
+		settings.Owner = owner
+
+		settings.HostedZoneId, err = getHostedZoneId(ctx, c.route53Api, settings)
+		if err == nil {
+			c.zones[settings.HostedZoneId] = true
+			desiredNames[settings.HostedZoneId+"/"+normalizeRecordName(settings.Domain)] = true
+
+			err = c.publish(ctx, settings, record.PublicIps)
+		}
+
+		if err != nil {
+			log.Printf("Error publishing '%v': %v\n", settings.Domain, err)
+			failed++
+		}
+	}
+
+	err = c.deleteStaleRecords(ctx, owner, desiredNames)
+
+	if failed > 0 {
+		return fmt.Errorf("%v of %v records failed", failed, len(records))
+	}
+
+	return err
+}
+
+func (c *Controller) publish(ctx context.Context, settings dnsSettings, publicIps []string) error {
+	outcome, err := publishRoute53RecordSet(ctx, c.route53Api, settings, publicIps)
+	if err != nil {
+		return err
+	}
+
+	// records published before they had an owner record are claimed too, so
+	// they are deleted once their tasks stop
+	if outcome == publishOutcomeUnchanged {
+		return claimRecord(ctx, c.route53Api, settings.HostedZoneId, settings)
+	}
+
+	return nil
+}
+
+// deleteStaleRecords deletes the records owned by the controller of this
+// cluster that no longer have any running task, in the zones it has seen.
+func (c *Controller) deleteStaleRecords(ctx context.Context, owner string, desiredNames map[string]bool) error {
+	zones := []string{}
+	for zone := range c.zones {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	failed := 0
+	for _, zone := range zones {
+		records, err := listOwnedRecords(ctx, c.route53Api, zone)
+		if err != nil {
+			log.Printf("Error listing the records of zone '%v': %v\n", zone, err)
+			failed++
+			continue
+		}
+
+		for _, record := range records {
+			name := aws.ToString(record.RecordSet.Name)
+			if record.Owner.Id != owner || desiredNames[zone+"/"+normalizeRecordName(name)] {
+				continue
+			}
+
+			err = deleteOwnedRecord(ctx, c.route53Api, zone, record)
+			if err != nil {
+				log.Printf("Error deleting '%v': %v\n", name, err)
+				failed++
+				continue
+			}
+
+			log.Printf("Deleted '%v', it has no running tasks\n", name)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v stale record deletions failed", failed)
+	}
+
+	return nil
+}
+
+// getDesiredRecords groups the public ips of the running tasks by the domain
+// configured for each task, sorted by domain.
+func (c *Controller) getDesiredRecords(ctx context.Context) ([]desiredRecord, error) {
+	enis := []string{}
+	eniSettings := map[string]dnsSettings{}
+
+	for _, service := range c.services {
+		serviceTags, err := getServiceTags(ctx, c.ecsApi, c.clusterName, service)
+		if err != nil {
+			return nil, err
+		}
+
+		serviceDefaults := c.defaults
+		err = applyDnsSettingsTags(&serviceDefaults, serviceTags)
+		if err != nil {
+			return nil, fmt.Errorf("error reading tags of service '%v': %v", service, err)
+		}
+
+		tasks, err := getServiceTasks(ctx, c.ecsApi, c.clusterName, service)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, task := range tasks {
+			eni, err := getTaskEni(task)
+			if err != nil {
+				log.Printf("Skipping task '%v': %v\n", aws.ToString(task.TaskArn), err)
+				continue
+			}
+
+			settings, err := getDnsSettings(ctx, c.ecsApi, c.clusterName, task, false, serviceDefaults)
+			if err != nil {
+				log.Printf("Skipping task '%v': %v\n", aws.ToString(task.TaskArn), err)
+				continue
+			}
+
+			enis = append(enis, eni)
+			eniSettings[eni] = settings
+		}
+	}
+
+	publicIps, err := getPublicIpsFromTaskEnis(ctx, c.ec2Api, enis)
+	if err != nil {
+		return nil, err
+	}
+
+	recordsByDomain := map[string]*desiredRecord{}
+	for _, eni := range enis {
+		publicIp, ok := publicIps[eni]
+		if !ok {
+			continue
+		}
+
+		settings := eniSettings[eni]
+
+		record, ok := recordsByDomain[settings.Domain]
+		if !ok {
+			record = &desiredRecord{Settings: settings}
+			recordsByDomain[settings.Domain] = record
+		}
+
+		record.PublicIps = append(record.PublicIps, publicIp)
+	}
+
+	records := []desiredRecord{}
+	for _, record := range recordsByDomain {
+		sort.Strings(record.PublicIps)
+		records = append(records, *record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Settings.Domain < records[j].Settings.Domain
+	})
+
+	return records, nil
+}
+
+// getServiceTasks returns the running tasks of the service, or of the whole
+// cluster when serviceName is empty.
+func getServiceTasks(ctx context.Context, ecsApi EcsApi, clusterName string, serviceName string) ([]ecsTypes.Task, error) {
+	taskArns := []string{}
+
+	listTasksInput := &ecs.ListTasksInput{
+		Cluster:       aws.String(clusterName),
+		DesiredStatus: ecsTypes.DesiredStatusRunning,
+	}
+	if len(serviceName) > 0 {
+		listTasksInput.ServiceName = aws.String(serviceName)
+	}
+
+	for {
+		listTasksOutput, err := ecsApi.ListTasks(ctx, listTasksInput)
+		if err != nil {
+			return nil, fmt.Errorf("error listing tasks of service '%v': %v", serviceName, err)
+		}
+
+		taskArns = append(taskArns, listTasksOutput.TaskArns...)
+
+		if listTasksOutput.NextToken == nil {
+			break
+		}
+
+		listTasksInput.NextToken = listTasksOutput.NextToken
+	}
+
+	tasks := []ecsTypes.Task{}
+
+	for start := 0; start < len(taskArns); start += describeTasksBatchSize {
+		end := start + describeTasksBatchSize
+		if end > len(taskArns) {
+			end = len(taskArns)
+		}
+
+		describeTasksInput := &ecs.DescribeTasksInput{
+			Cluster: aws.String(clusterName),
+			Tasks:   taskArns[start:end],
+			Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags},
+		}
+
+		describeTasksOutput, err := ecsApi.DescribeTasks(ctx, describeTasksInput)
+		if err != nil {
+			return nil, fmt.Errorf("error describing tasks of service '%v': %v", serviceName, err)
+		}
+
+		tasks = append(tasks, describeTasksOutput.Tasks...)
+	}
+
+	return tasks, nil
+}
+
+// getPublicIpsFromTaskEnis returns the public ip of each eni. Enis without a
+// public ip are left out of the result.
+func getPublicIpsFromTaskEnis(ctx context.Context, ec2Api Ec2Api, taskEnis []string) (map[string]string, error) {
+	networkInterfaces, err := describeTaskEnis(ctx, ec2Api, taskEnis)
+	if err != nil {
+		return nil, err
+	}
+
+	publicIps := map[string]string{}
+
+	for _, networkInterface := range networkInterfaces {
+		if networkInterface.Association == nil || networkInterface.Association.PublicIp == nil {
+			log.Printf("The eni '%v' has no public ip\n", aws.ToString(networkInterface.NetworkInterfaceId))
+			continue
+		}
+
+		publicIps[*networkInterface.NetworkInterfaceId] = *networkInterface.Association.PublicIp
+	}
+
+	return publicIps, nil
+}
+
+// describeTaskEnis looks the enis up with a filter instead of their ids, as
+// EC2 fails the whole call when one of the ids no longer exists, which happens
+// when a task stops after it was listed. The missing enis are left out.
+func describeTaskEnis(ctx context.Context, ec2Api Ec2Api, taskEnis []string) ([]ec2Types.NetworkInterface, error) {
+	networkInterfaces := []ec2Types.NetworkInterface{}
+
+	for start := 0; start < len(taskEnis); start += describeNetworkInterfacesBatchSize {
+		end := start + describeNetworkInterfacesBatchSize
+		if end > len(taskEnis) {
+			end = len(taskEnis)
+		}
+
+		describeNetworkInterfaceInput := &ec2.DescribeNetworkInterfacesInput{
+			Filters: []ec2Types.Filter{{Name: aws.String("network-interface-id"), Values: taskEnis[start:end]}},
+		}
+
+		for {
+			describeNetworkInterfacesOutput, err := ec2Api.DescribeNetworkInterfaces(ctx, describeNetworkInterfaceInput)
+			if err != nil {
+				return nil, fmt.Errorf("error describing network interfaces: %v", err)
+			}
+
+			networkInterfaces = append(networkInterfaces, describeNetworkInterfacesOutput.NetworkInterfaces...)
+
+			if describeNetworkInterfacesOutput.NextToken == nil {
+				break
+			}
+
+			describeNetworkInterfaceInput.NextToken = describeNetworkInterfacesOutput.NextToken
+		}
+	}
+
+	return networkInterfaces, nil
+}
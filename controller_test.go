@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func taskWithEni(taskArn string, eni string) ecsTypes.Task {
+	return ecsTypes.Task{
+		TaskArn: aws.String(taskArn),
+		Attachments: []ecsTypes.Attachment{
+			{
+				Details: []ecsTypes.KeyValuePair{
+					{Name: aws.String("networkInterfaceId"), Value: aws.String(eni)},
+				},
+			},
+		},
+	}
+}
+
+func networkInterfaceWithIp(eni string, publicIp string) ec2Types.NetworkInterface {
+	return ec2Types.NetworkInterface{
+		NetworkInterfaceId: aws.String(eni),
+		Association: &ec2Types.NetworkInterfaceAssociation{
+			PublicIp: aws.String(publicIp),
+		},
+	}
+}
+
+func Test_GetServiceTasks_ListTasks_Error(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+
+	listTasksInput := &ecs.ListTasksInput{
+		Cluster:       aws.String("cluster"),
+		ServiceName:   aws.String("service"),
+		DesiredStatus: ecsTypes.DesiredStatusRunning,
+	}
+
+	mockedEcsApi.On("ListTasks", ctx, listTasksInput).Return(nil, fmt.Errorf("some error"))
+
+	result, err := getServiceTasks(ctx, mockedEcsApi, "cluster", "service")
+
+	assert.Nil(t, result)
+	assert.EqualError(t, err, "error listing tasks of service 'service': some error")
+
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func Test_GetServiceTasks_Paginated_Ok(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+
+	firstPageInput := &ecs.ListTasksInput{
+		Cluster:       aws.String("cluster"),
+		ServiceName:   aws.String("service"),
+		DesiredStatus: ecsTypes.DesiredStatusRunning,
+	}
+	secondPageInput := &ecs.ListTasksInput{
+		Cluster:       aws.String("cluster"),
+		ServiceName:   aws.String("service"),
+		DesiredStatus: ecsTypes.DesiredStatusRunning,
+		NextToken:     aws.String("token"),
+	}
+
+	mockedEcsApi.On("ListTasks", ctx, firstPageInput).Return(&ecs.ListTasksOutput{TaskArns: []string{"task1"}, NextToken: aws.String("token")}, nil).Once()
+	mockedEcsApi.On("ListTasks", ctx, secondPageInput).Return(&ecs.ListTasksOutput{TaskArns: []string{"task2"}}, nil).Once()
+
+	describeTasksInput := &ecs.DescribeTasksInput{
+		Cluster: aws.String("cluster"),
+		Tasks:   []string{"task1", "task2"},
+		Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags},
+	}
+
+	tasks := []ecsTypes.Task{taskWithEni("task1", "eni1"), taskWithEni("task2", "eni2")}
+
+	mockedEcsApi.On("DescribeTasks", ctx, describeTasksInput).Return(&ecs.DescribeTasksOutput{Tasks: tasks}, nil).Once()
+
+	result, err := getServiceTasks(ctx, mockedEcsApi, "cluster", "service")
+
+	assert.Equal(t, tasks, result)
+	assert.Nil(t, err)
+
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func taskEnisInput(enis ...string) *ec2.DescribeNetworkInterfacesInput {
+	return &ec2.DescribeNetworkInterfacesInput{
+		Filters: []ec2Types.Filter{{Name: aws.String("network-interface-id"), Values: enis}},
+	}
+}
+
+func Test_GetPublicIpsFromTaskEnis_MissingEni(t *testing.T) {
+	ctx := context.TODO()
+	mockedEc2Api := NewMockedEc2Api()
+
+	output := &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []ec2Types.NetworkInterface{networkInterfaceWithIp("eni1", "ip1")},
+	}
+
+	mockedEc2Api.On("DescribeNetworkInterfaces", ctx, taskEnisInput("eni1", "stoppedEni")).Return(output, nil).Once()
+
+	result, err := getPublicIpsFromTaskEnis(ctx, mockedEc2Api, []string{"eni1", "stoppedEni"})
+
+	assert.Equal(t, map[string]string{"eni1": "ip1"}, result)
+	assert.Nil(t, err)
+
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_GetPublicIpsFromTaskEnis_Error(t *testing.T) {
+	ctx := context.TODO()
+	mockedEc2Api := NewMockedEc2Api()
+
+	input := taskEnisInput("eni1")
+
+	mockedEc2Api.On("DescribeNetworkInterfaces", ctx, input).Return(nil, fmt.Errorf("some error"))
+
+	result, err := getPublicIpsFromTaskEnis(ctx, mockedEc2Api, []string{"eni1"})
+
+	assert.Nil(t, result)
+	assert.EqualError(t, err, "error describing network interfaces: some error")
+
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_GetPublicIpsFromTaskEnis_Batches(t *testing.T) {
+	ctx := context.TODO()
+	mockedEc2Api := NewMockedEc2Api()
+
+	enis := []string{}
+	for i := 0; i < describeNetworkInterfacesBatchSize+1; i++ {
+		enis = append(enis, fmt.Sprintf("eni%v", i))
+	}
+
+	firstBatchOutput := &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []ec2Types.NetworkInterface{
+			networkInterfaceWithIp("eni0", "ip0"),
+			{NetworkInterfaceId: aws.String("eni1")},
+		},
+	}
+	secondBatchOutput := &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []ec2Types.NetworkInterface{
+			networkInterfaceWithIp("eni100", "ip100"),
+		},
+	}
+
+	mockedEc2Api.On("DescribeNetworkInterfaces", ctx, taskEnisInput(enis[:100]...)).Return(firstBatchOutput, nil).Once()
+	mockedEc2Api.On("DescribeNetworkInterfaces", ctx, taskEnisInput(enis[100:]...)).Return(secondBatchOutput, nil).Once()
+
+	result, err := getPublicIpsFromTaskEnis(ctx, mockedEc2Api, enis)
+
+	assert.Equal(t, map[string]string{"eni0": "ip0", "eni100": "ip100"}, result)
+	assert.Nil(t, err)
+
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_Controller_Reconcile_Ok(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedEc2Api := NewMockedEc2Api()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	describeServicesInput := &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []string{"service"},
+		Include:  []ecsTypes.ServiceField{ecsTypes.ServiceFieldTags},
+	}
+	describeServicesOutput := &ecs.DescribeServicesOutput{
+		Services: []ecsTypes.Service{
+			{
+				Tags: []ecsTypes.Tag{
+					{Key: aws.String(domainTagKey), Value: aws.String("domain")},
+					{Key: aws.String(hostedZoneIdTagKey), Value: aws.String("zoneId")},
+				},
+			},
+		},
+	}
+
+	mockedEcsApi.On("DescribeServices", ctx, describeServicesInput).Return(describeServicesOutput, nil).Once()
+
+	listTasksInput := &ecs.ListTasksInput{
+		Cluster:       aws.String("cluster"),
+		ServiceName:   aws.String("service"),
+		DesiredStatus: ecsTypes.DesiredStatusRunning,
+	}
+
+	mockedEcsApi.On("ListTasks", ctx, listTasksInput).Return(&ecs.ListTasksOutput{TaskArns: []string{"task1", "task2", "task3"}}, nil).Once()
+
+	describeTasksInput := &ecs.DescribeTasksInput{
+		Cluster: aws.String("cluster"),
+		Tasks:   []string{"task1", "task2", "task3"},
+		Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags},
+	}
+	describeTasksOutput := &ecs.DescribeTasksOutput{
+		Tasks: []ecsTypes.Task{
+			taskWithEni("task1", "eni1"),
+			taskWithEni("task2", "eni2"),
+			{TaskArn: aws.String("task3")},
+		},
+	}
+
+	mockedEcsApi.On("DescribeTasks", ctx, describeTasksInput).Return(describeTasksOutput, nil).Once()
+
+	describeNetworkInterfacesInput := taskEnisInput("eni1", "eni2")
+	describeNetworkInterfacesOutput := &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []ec2Types.NetworkInterface{
+			networkInterfaceWithIp("eni1", "ip2"),
+			networkInterfaceWithIp("eni2", "ip1"),
+		},
+	}
+
+	mockedEc2Api.On("DescribeNetworkInterfaces", ctx, describeNetworkInterfacesInput).Return(describeNetworkInterfacesOutput, nil).Once()
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(&route53.ListResourceRecordSetsOutput{}, nil).Once()
+	mockOwnerRecord(mockedRoute53Api, "zoneId", "domain", nil)
+
+	changeInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{
+					Action: "UPSERT",
+					ResourceRecordSet: &route53Types.ResourceRecordSet{
+						Type: route53Types.RRTypeA,
+						Name: aws.String("domain"),
+						TTL:  aws.Int64(300),
+						ResourceRecords: []route53Types.ResourceRecord{
+							{Value: aws.String("ip1")},
+							{Value: aws.String("ip2")},
+						},
+					},
+				},
+				ownerChange("domain", recordOwner{Id: "controller/cluster"}),
+			},
+		},
+		HostedZoneId: aws.String("zoneId"),
+	}
+	changeOutput := &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53Types.ChangeInfo{Status: route53Types.ChangeStatusPending},
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, changeInput).Return(changeOutput, nil).Once()
+
+	staleRecordSet := route53Types.ResourceRecordSet{Name: aws.String("stale."), Type: route53Types.RRTypeA}
+	staleOwnerSet := *ownerRecordSet("stale.", recordOwner{Id: "controller/cluster"})
+	sidecarRecordSet := route53Types.ResourceRecordSet{Name: aws.String("sidecar."), Type: route53Types.RRTypeA}
+	sidecarOwnerSet := *ownerRecordSet("sidecar.", recordOwner{Id: "task/cluster"})
+
+	listOutput := &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets: []route53Types.ResourceRecordSet{
+			{Name: aws.String("domain."), Type: route53Types.RRTypeA},
+			*ownerRecordSet("domain.", recordOwner{Id: "controller/cluster"}),
+			staleRecordSet,
+			staleOwnerSet,
+			sidecarRecordSet,
+			sidecarOwnerSet,
+		},
+	}
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String("zoneId")}).Return(listOutput, nil).Once()
+
+	deleteInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &staleRecordSet},
+				{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &staleOwnerSet},
+			},
+		},
+		HostedZoneId: aws.String("zoneId"),
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, deleteInput).Return(&route53.ChangeResourceRecordSetsOutput{}, nil).Once()
+
+	controller := NewController(mockedEcsApi, mockedEc2Api, mockedRoute53Api, "cluster", []string{"service"}, dnsSettings{TTL: 300})
+
+	err := controller.Reconcile(ctx)
+
+	assert.Nil(t, err)
+
+	mockedEcsApi.AssertExpectations(t)
+	mockedEc2Api.AssertExpectations(t)
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_Controller_Reconcile_PublishError(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedEc2Api := NewMockedEc2Api()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	describeServicesInput := &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []string{"service"},
+		Include:  []ecsTypes.ServiceField{ecsTypes.ServiceFieldTags},
+	}
+
+	mockedEcsApi.On("DescribeServices", ctx, describeServicesInput).Return(&ecs.DescribeServicesOutput{Services: []ecsTypes.Service{{}}}, nil).Once()
+
+	listTasksInput := &ecs.ListTasksInput{
+		Cluster:       aws.String("cluster"),
+		ServiceName:   aws.String("service"),
+		DesiredStatus: ecsTypes.DesiredStatusRunning,
+	}
+
+	mockedEcsApi.On("ListTasks", ctx, listTasksInput).Return(&ecs.ListTasksOutput{TaskArns: []string{"task1"}}, nil).Once()
+
+	describeTasksInput := &ecs.DescribeTasksInput{
+		Cluster: aws.String("cluster"),
+		Tasks:   []string{"task1"},
+		Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags},
+	}
+
+	mockedEcsApi.On("DescribeTasks", ctx, describeTasksInput).Return(&ecs.DescribeTasksOutput{Tasks: []ecsTypes.Task{taskWithEni("task1", "eni1")}}, nil).Once()
+
+	describeNetworkInterfacesInput := taskEnisInput("eni1")
+	describeNetworkInterfacesOutput := &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []ec2Types.NetworkInterface{networkInterfaceWithIp("eni1", "ip1")},
+	}
+
+	mockedEc2Api.On("DescribeNetworkInterfaces", ctx, describeNetworkInterfacesInput).Return(describeNetworkInterfacesOutput, nil).Once()
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(nil, fmt.Errorf("some error")).Once()
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String("zoneId")}).Return(&route53.ListResourceRecordSetsOutput{}, nil).Once()
+
+	controller := NewController(mockedEcsApi, mockedEc2Api, mockedRoute53Api, "cluster", []string{"service"}, dnsSettings{Domain: "domain", HostedZoneId: "zoneId", TTL: 300})
+
+	err := controller.Reconcile(ctx)
+
+	assert.EqualError(t, err, "1 of 1 records failed")
+
+	mockedEcsApi.AssertExpectations(t)
+	mockedEc2Api.AssertExpectations(t)
+	mockedRoute53Api.AssertExpectations(t)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// The dns plugin protocol lets any executable act as a DNSProvider. For every
+// operation the sidecar runs the plugin once, writes a dnsPluginRequest as
+// json to its stdin and reads a dnsPluginResponse as json from its stdout.
+// Whatever the plugin writes to stderr is logged. The plugin must exit with
+// code 0 and answer with the same protocol version it was asked with; errors
+// are reported in the response error field. The zone of the request is the
+// hosted zone tag of the task, or --dns-plugin-zone, or else the parent
+// domain of the record (example.com for app.example.com).
+//
+// Records shared by several tasks are changed with a current field in the
+// upsert and delete requests, holding the values and ttl the sidecar read
+// before (no values when the record didn't exist). When the record no longer
+// matches them, because another task changed it in the meantime, the plugin
+// must not change it and answers with conflict set, so the sidecar reads the
+// record again and retries. Plugins that ignore the current field still work,
+// but concurrent changes of a shared record may drop ips.
+const (
+	dnsPluginProtocolVersion = 1
+
+	// dnsPluginActionGet asks for the current values and ttl of the record.
+	// A record that doesn't exist is answered with no values.
+	dnsPluginActionGet = "get"
+	// dnsPluginActionUpsert creates the record or replaces its values and ttl.
+	dnsPluginActionUpsert = "upsert"
+	// dnsPluginActionDelete deletes the record, which has the given values and ttl.
+	dnsPluginActionDelete = "delete"
+
+	dnsPluginRecordType = "A"
+
+	defaultDnsPluginTimeout = 30 * time.Second
+)
+
+type dnsPluginRecord struct {
+	Values []string `json:"values,omitempty"`
+	TTL    int64    `json:"ttl,omitempty"`
+}
+
+type dnsPluginRequest struct {
+	Version int              `json:"version"`
+	Action  string           `json:"action"`
+	Zone    string           `json:"zone"`
+	Name    string           `json:"name"`
+	Type    string           `json:"type"`
+	TTL     int64            `json:"ttl,omitempty"`
+	Values  []string         `json:"values,omitempty"`
+	Current *dnsPluginRecord `json:"current,omitempty"`
+}
+
+type dnsPluginResponse struct {
+	Version  int      `json:"version"`
+	Values   []string `json:"values,omitempty"`
+	TTL      int64    `json:"ttl,omitempty"`
+	Conflict bool     `json:"conflict,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// errDnsPluginConflict is returned when the record changed since it was read.
+var errDnsPluginConflict = errors.New("the record was changed by another task")
+
+// ExecDNSProvider is a DNSProvider backed by a plugin executable.
+type ExecDNSProvider struct {
+	path    string
+	args    []string
+	timeout time.Duration
+}
+
+func NewExecDNSProvider(path string, args []string, timeout time.Duration) *ExecDNSProvider {
+	return &ExecDNSProvider{path: path, args: args, timeout: timeout}
+}
+
+func (p *ExecDNSProvider) Plan(ctx context.Context, settings dnsSettings, publicIps []string) (recordPlan, error) {
+	current, err := p.call(ctx, dnsPluginActionGet, settings, 0, nil, nil)
+	if err != nil {
+		return recordPlan{}, err
+	}
+
+	return newRecordPlan(settings, publicIps, len(current.Values) > 0, current.Values, current.TTL), nil
+}
+
+func (p *ExecDNSProvider) Apply(ctx context.Context, settings dnsSettings, plan recordPlan) (string, error) {
+	if !plan.hasChanges() {
+		log.Printf("Record '%v' already points to '%v' with ttl %v, nothing to change\n", settings.Domain, strings.Join(plan.NewIps, ","), settings.TTL)
+
+		return publishOutcomeUnchanged, nil
+	}
+
+	_, err := p.call(ctx, dnsPluginActionUpsert, settings, settings.TTL, plan.NewIps, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if plan.Action == planActionCreate {
+		return publishOutcomeCreated, nil
+	}
+
+	return publishOutcomeUpdated, nil
+}
+
+// AddIp adds the ip to the ones already in the record, for records shared by
+// several tasks.
+func (p *ExecDNSProvider) AddIp(ctx context.Context, settings dnsSettings, publicIp string) (string, error) {
+	return p.updateShared(ctx, settings, func(current dnsPluginRecord) (dnsPluginRecord, bool) {
+		if containsString(current.Values, publicIp) {
+			log.Printf("Record '%v' already contains '%v', nothing to change\n", settings.Domain, publicIp)
+
+			return dnsPluginRecord{}, false
+		}
+
+		return dnsPluginRecord{Values: append(append([]string{}, current.Values...), publicIp), TTL: settings.TTL}, true
+	})
+}
+
+func (p *ExecDNSProvider) Withdraw(ctx context.Context, settings dnsSettings, publicIp string) (string, error) {
+	return p.updateShared(ctx, settings, func(current dnsPluginRecord) (dnsPluginRecord, bool) {
+		found := false
+		remainingIps := []string{}
+		for _, ip := range current.Values {
+			if ip == publicIp {
+				found = true
+			} else {
+				remainingIps = append(remainingIps, ip)
+			}
+		}
+
+		if !found {
+			log.Printf("Record '%v' does not contain '%v', nothing to withdraw\n", settings.Domain, publicIp)
+
+			return dnsPluginRecord{}, false
+		}
+
+		return dnsPluginRecord{Values: remainingIps, TTL: current.TTL}, true
+	})
+}
+
+// updateShared replaces the record with the one returned by update, deleting
+// it when it has no values. Like updateSharedRecordSet does with Route53, the
+// change names the record it is based on and is retried on a conflict.
+func (p *ExecDNSProvider) updateShared(ctx context.Context, settings dnsSettings, update func(current dnsPluginRecord) (dnsPluginRecord, bool)) (string, error) {
+	for attempt := 1; ; attempt++ {
+		response, err := p.call(ctx, dnsPluginActionGet, settings, 0, nil, nil)
+		if err != nil {
+			return "", err
+		}
+
+		current := dnsPluginRecord{Values: response.Values, TTL: response.TTL}
+
+		desired, changed := update(current)
+		if !changed {
+			return publishOutcomeUnchanged, nil
+		}
+
+		outcome := publishOutcomeUpdated
+		switch {
+		case len(desired.Values) == 0:
+			_, err = p.call(ctx, dnsPluginActionDelete, settings, current.TTL, current.Values, &current)
+			outcome = publishOutcomeDeleted
+		default:
+			_, err = p.call(ctx, dnsPluginActionUpsert, settings, desired.TTL, desired.Values, &current)
+			if len(current.Values) == 0 {
+				outcome = publishOutcomeCreated
+			}
+		}
+
+		if err == nil {
+			return outcome, nil
+		}
+
+		if !errors.Is(err, errDnsPluginConflict) || attempt == sharedRecordSetAttempts {
+			return "", err
+		}
+
+		log.Printf("Record '%v' was changed by another task, retrying\n", settings.Domain)
+	}
+}
+
+// getDnsPluginZone returns the zone sent to the plugin, see the protocol above.
+func getDnsPluginZone(settings dnsSettings, defaultZone string) string {
+	if len(settings.HostedZoneId) > 0 {
+		return settings.HostedZoneId
+	}
+
+	if len(defaultZone) > 0 {
+		return defaultZone
+	}
+
+	parts := strings.SplitN(strings.TrimSuffix(settings.Domain, "."), ".", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+
+	return parts[1]
+}
+
+func (p *ExecDNSProvider) call(ctx context.Context, action string, settings dnsSettings, ttl int64, values []string, current *dnsPluginRecord) (dnsPluginResponse, error) {
+	request := dnsPluginRequest{
+		Version: dnsPluginProtocolVersion,
+		Action:  action,
+		Zone:    settings.HostedZoneId,
+		Name:    settings.Domain,
+		Type:    dnsPluginRecordType,
+		TTL:     ttl,
+		Values:  values,
+		Current: current,
+	}
+
+	input, err := json.Marshal(request)
+	if err != nil {
+		return dnsPluginResponse{}, fmt.Errorf("error encoding the '%v' request to the dns plugin: %v", action, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, p.path, p.args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+
+	for _, line := range strings.Split(strings.TrimRight(stderr.String(), "\n"), "\n") {
+		if len(line) > 0 {
+			log.Printf("[dns plugin] %v\n", line)
+		}
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return dnsPluginResponse{}, fmt.Errorf("dns plugin '%v' timed out after %v", action, p.timeout)
+	}
+
+	if err != nil {
+		return dnsPluginResponse{}, fmt.Errorf("error running the dns plugin '%v': %v", action, err)
+	}
+
+	response := dnsPluginResponse{}
+
+	err = json.Unmarshal(stdout.Bytes(), &response)
+	if err != nil {
+		return dnsPluginResponse{}, fmt.Errorf("error decoding the '%v' response of the dns plugin: %v", action, err)
+	}
+
+	if response.Version != dnsPluginProtocolVersion {
+		return dnsPluginResponse{}, fmt.Errorf("dns plugin answered with protocol version %v, expected %v", response.Version, dnsPluginProtocolVersion)
+	}
+
+	if response.Conflict {
+		return dnsPluginResponse{}, fmt.Errorf("dns plugin '%v' of record '%v': %w", action, settings.Domain, errDnsPluginConflict)
+	}
+
+	if len(response.Error) > 0 {
+		return dnsPluginResponse{}, fmt.Errorf("dns plugin '%v' failed: %v", action, response.Error)
+	}
+
+	return response, nil
+}
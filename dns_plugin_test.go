@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	samplePluginStateEnv = "SAMPLE_DNS_PLUGIN_STATE"
+	// samplePluginConcurrentIpEnv makes the sample plugin add the ip to the
+	// record right after a get, like another task would, while it is missing.
+	samplePluginConcurrentIpEnv = "SAMPLE_DNS_PLUGIN_CONCURRENT_IP"
+)
+
+// Test_SampleDnsPlugin isn't a real test: it is the sample plugin run by the
+// other tests, keeping the records in a json file.
+func Test_SampleDnsPlugin(t *testing.T) {
+	statePath := os.Getenv(samplePluginStateEnv)
+	if len(statePath) == 0 {
+		return
+	}
+
+	defer os.Exit(0)
+
+	respond := func(response dnsPluginResponse) {
+		response.Version = dnsPluginProtocolVersion
+		json.NewEncoder(os.Stdout).Encode(response)
+	}
+
+	request := dnsPluginRequest{}
+
+	err := json.NewDecoder(os.Stdin).Decode(&request)
+	if err != nil {
+		respond(dnsPluginResponse{Error: err.Error()})
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%v %v\n", request.Action, request.Name)
+
+	if request.Version != dnsPluginProtocolVersion {
+		respond(dnsPluginResponse{Error: fmt.Sprintf("unsupported version %v", request.Version)})
+		return
+	}
+
+	records := map[string]dnsPluginResponse{}
+
+	content, err := ioutil.ReadFile(statePath)
+	if err == nil {
+		json.Unmarshal(content, &records)
+	}
+
+	key := request.Zone + "/" + request.Name
+
+	if request.Action == dnsPluginActionGet {
+		respond(records[key])
+
+		concurrentIp := os.Getenv(samplePluginConcurrentIpEnv)
+		if len(concurrentIp) > 0 && !containsString(records[key].Values, concurrentIp) {
+			records[key] = dnsPluginResponse{Values: append(records[key].Values, concurrentIp), TTL: records[key].TTL}
+			content, _ = json.Marshal(records)
+			ioutil.WriteFile(statePath, content, 0644)
+		}
+		return
+	}
+
+	if request.Current != nil && !(sameIps(request.Current.Values, records[key].Values) && request.Current.TTL == records[key].TTL) {
+		respond(dnsPluginResponse{Conflict: true})
+		return
+	}
+
+	switch request.Action {
+	case dnsPluginActionUpsert:
+		records[key] = dnsPluginResponse{Values: request.Values, TTL: request.TTL}
+	case dnsPluginActionDelete:
+		delete(records, key)
+	default:
+		respond(dnsPluginResponse{Error: fmt.Sprintf("unknown action '%v'", request.Action)})
+		return
+	}
+
+	content, _ = json.Marshal(records)
+	ioutil.WriteFile(statePath, content, 0644)
+
+	respond(dnsPluginResponse{})
+}
+
+func newSampleDnsPlugin(t *testing.T) (*ExecDNSProvider, string) {
+	statePath := filepath.Join(t.TempDir(), "records.json")
+	t.Setenv(samplePluginStateEnv, statePath)
+
+	return NewExecDNSProvider(os.Args[0], []string{"-test.run=Test_SampleDnsPlugin"}, 10*time.Second), statePath
+}
+
+func Test_ExecDNSProvider_PublishAndWithdraw(t *testing.T) {
+	provider, _ := newSampleDnsPlugin(t)
+	settings := dnsSettings{Domain: "app.example.com", HostedZoneId: "zoneId", TTL: 300}
+	ctx := context.TODO()
+
+	plan, err := provider.Plan(ctx, settings, []string{"1.1.1.1"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, recordPlan{Action: planActionCreate, HostedZoneId: "zoneId", Name: "app.example.com", NewIps: []string{"1.1.1.1"}, NewTTL: 300}, plan)
+
+	outcome, err := provider.Apply(ctx, settings, plan)
+
+	assert.Nil(t, err)
+	assert.Equal(t, publishOutcomeCreated, outcome)
+
+	plan, err = provider.Plan(ctx, settings, []string{"1.1.1.1", "2.2.2.2"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, planActionUpdate, plan.Action)
+	assert.Equal(t, []string{"1.1.1.1"}, plan.OldIps)
+	assert.Equal(t, int64(300), plan.OldTTL)
+
+	outcome, err = provider.Apply(ctx, settings, plan)
+
+	assert.Nil(t, err)
+	assert.Equal(t, publishOutcomeUpdated, outcome)
+
+	outcome, err = provider.Withdraw(ctx, settings, "1.1.1.1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, publishOutcomeUpdated, outcome)
+
+	outcome, err = provider.Withdraw(ctx, settings, "1.1.1.1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, publishOutcomeUnchanged, outcome)
+
+	outcome, err = provider.Withdraw(ctx, settings, "2.2.2.2")
+
+	assert.Nil(t, err)
+	assert.Equal(t, publishOutcomeDeleted, outcome)
+
+	plan, err = provider.Plan(ctx, settings, []string{"1.1.1.1"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, planActionCreate, plan.Action)
+}
+
+func Test_ExecDNSProvider_Unchanged(t *testing.T) {
+	provider, statePath := newSampleDnsPlugin(t)
+	settings := dnsSettings{Domain: "app.example.com", HostedZoneId: "zoneId", TTL: 300}
+
+	ioutil.WriteFile(statePath, []byte(`{"zoneId/app.example.com":{"version":1,"values":["1.1.1.1"],"ttl":300}}`), 0644)
+
+	plan, err := provider.Plan(context.TODO(), settings, []string{"1.1.1.1"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, planActionNoop, plan.Action)
+
+	outcome, err := provider.Apply(context.TODO(), settings, plan)
+
+	assert.Nil(t, err)
+	assert.Equal(t, publishOutcomeUnchanged, outcome)
+}
+
+func Test_ExecDNSProvider_ExitCode(t *testing.T) {
+	provider := NewExecDNSProvider("sh", []string{"-c", "echo broken >&2; exit 1"}, time.Second)
+
+	_, err := provider.Plan(context.TODO(), dnsSettings{Domain: "app.example.com"}, []string{"1.1.1.1"})
+
+	assert.EqualError(t, err, "error running the dns plugin 'get': exit status 1")
+}
+
+func Test_ExecDNSProvider_WrongVersion(t *testing.T) {
+	provider := NewExecDNSProvider("sh", []string{"-c", `echo '{"version":2}'`}, time.Second)
+
+	_, err := provider.Plan(context.TODO(), dnsSettings{Domain: "app.example.com"}, []string{"1.1.1.1"})
+
+	assert.EqualError(t, err, "dns plugin answered with protocol version 2, expected 1")
+}
+
+func Test_ExecDNSProvider_ErrorResponse(t *testing.T) {
+	provider := NewExecDNSProvider("sh", []string{"-c", `cat > /dev/null; echo '{"version":1,"error":"zone not found"}'`}, time.Second)
+
+	_, err := provider.Withdraw(context.TODO(), dnsSettings{Domain: "app.example.com"}, "1.1.1.1")
+
+	assert.EqualError(t, err, "dns plugin 'get' failed: zone not found")
+}
+
+func Test_ExecDNSProvider_Timeout(t *testing.T) {
+	provider := NewExecDNSProvider("sleep", []string{"5"}, 50*time.Millisecond)
+
+	_, err := provider.Plan(context.TODO(), dnsSettings{Domain: "app.example.com"}, []string{"1.1.1.1"})
+
+	assert.EqualError(t, err, "dns plugin 'get' timed out after 50ms")
+}
+
+func Test_GetDnsPluginZone(t *testing.T) {
+	assert.Equal(t, "zoneId", getDnsPluginZone(dnsSettings{Domain: "app.example.com", HostedZoneId: "zoneId"}, "defaultZone"))
+	assert.Equal(t, "defaultZone", getDnsPluginZone(dnsSettings{Domain: "app.example.com"}, "defaultZone"))
+	assert.Equal(t, "example.com", getDnsPluginZone(dnsSettings{Domain: "app.example.com."}, ""))
+	assert.Equal(t, "", getDnsPluginZone(dnsSettings{Domain: "localhost"}, ""))
+}
@@ -0,0 +1,38 @@
+package main
+
+import "context"
+
+// DNSProvider keeps the A record of the task in a DNS service. The outcomes
+// are the publishOutcome* constants.
+type DNSProvider interface {
+	Plan(ctx context.Context, settings dnsSettings, publicIps []string) (recordPlan, error)
+	Apply(ctx context.Context, settings dnsSettings, plan recordPlan) (string, error)
+	// AddIp adds the ip to a record shared by several tasks, retrying when
+	// another task changes the record at the same time.
+	AddIp(ctx context.Context, settings dnsSettings, publicIp string) (string, error)
+	Withdraw(ctx context.Context, settings dnsSettings, publicIp string) (string, error)
+}
+
+type Route53DNSProvider struct {
+	route53Api Route53Api
+}
+
+func NewRoute53DNSProvider(route53Api Route53Api) *Route53DNSProvider {
+	return &Route53DNSProvider{route53Api: route53Api}
+}
+
+func (p *Route53DNSProvider) Plan(ctx context.Context, settings dnsSettings, publicIps []string) (recordPlan, error) {
+	return planRoute53RecordSet(ctx, p.route53Api, settings, publicIps)
+}
+
+func (p *Route53DNSProvider) Apply(ctx context.Context, settings dnsSettings, plan recordPlan) (string, error) {
+	return applyRoute53Plan(ctx, p.route53Api, settings, plan)
+}
+
+func (p *Route53DNSProvider) AddIp(ctx context.Context, settings dnsSettings, publicIp string) (string, error) {
+	return addIpToRoute53RecordSet(ctx, p.route53Api, settings, publicIp)
+}
+
+func (p *Route53DNSProvider) Withdraw(ctx context.Context, settings dnsSettings, publicIp string) (string, error) {
+	return withdrawIpFromRoute53RecordSet(ctx, p.route53Api, settings, publicIp)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	defaultTTL = 300
+
+	domainTagKey       = "ecs-sidecar:domain"
+	hostedZoneIdTagKey = "ecs-sidecar:hosted-zone-id"
+	ttlTagKey          = "ecs-sidecar:ttl"
+
+	serviceGroupPrefix = "service:"
+)
+
+type dnsSettings struct {
+	Domain       string
+	HostedZoneId string
+	TTL          int64
+	// Owner is written to the owner record of the A record, see recordOwnerId.
+	Owner string
+}
+
+// getDnsSettings starts from the given defaults and overrides them with the
+// owning service tags (when useServiceTags is set) and then with the task tags,
+// so a task tag always wins over a service tag.
+func getDnsSettings(ctx context.Context, ecsApi EcsApi, clusterName string, task ecsTypes.Task, useServiceTags bool, defaults dnsSettings) (dnsSettings, error) {
+	settings := defaults
+
+	if useServiceTags {
+		serviceTags, err := getTaskServiceTags(ctx, ecsApi, clusterName, task)
+		if err != nil {
+			return dnsSettings{}, err
+		}
+
+		err = applyDnsSettingsTags(&settings, serviceTags)
+		if err != nil {
+			return dnsSettings{}, fmt.Errorf("error reading service tags: %v", err)
+		}
+	}
+
+	err := applyDnsSettingsTags(&settings, task.Tags)
+	if err != nil {
+		return dnsSettings{}, fmt.Errorf("error reading task tags: %v", err)
+	}
+
+	if len(settings.Domain) == 0 {
+		return dnsSettings{}, fmt.Errorf("no domain configured")
+	}
+
+	log.Printf("DNS settings: domain '%v', hosted zone '%v', ttl %v\n", settings.Domain, settings.HostedZoneId, settings.TTL)
+
+	return settings, nil
+}
+
+func getTaskServiceTags(ctx context.Context, ecsApi EcsApi, clusterName string, task ecsTypes.Task) ([]ecsTypes.Tag, error) {
+	serviceName := getTaskServiceName(task)
+	if len(serviceName) == 0 {
+		return nil, nil
+	}
+
+	return getServiceTags(ctx, ecsApi, clusterName, serviceName)
+}
+
+// getTaskServiceName returns the service that started the task, or an empty
+// string for standalone tasks.
+func getTaskServiceName(task ecsTypes.Task) string {
+	if task.Group == nil || !strings.HasPrefix(*task.Group, serviceGroupPrefix) {
+		return ""
+	}
+
+	return strings.TrimPrefix(*task.Group, serviceGroupPrefix)
+}
+
+func getServiceTags(ctx context.Context, ecsApi EcsApi, clusterName string, serviceName string) ([]ecsTypes.Tag, error) {
+	describeServicesInput := &ecs.DescribeServicesInput{
+		Cluster:  aws.String(clusterName),
+		Services: []string{serviceName},
+		Include:  []ecsTypes.ServiceField{ecsTypes.ServiceFieldTags},
+	}
+
+	describeServicesOutput, err := ecsApi.DescribeServices(ctx, describeServicesInput)
+	if err != nil {
+		return nil, fmt.Errorf("error describing service '%v': %v", serviceName, err)
+	}
+
+	if len(describeServicesOutput.Services) == 0 {
+		return nil, fmt.Errorf("service '%v' not found", serviceName)
+	}
+
+	return describeServicesOutput.Services[0].Tags, nil
+}
+
+func applyDnsSettingsTags(settings *dnsSettings, tags []ecsTypes.Tag) error {
+	for _, tag := range tags {
+		if tag.Key == nil || tag.Value == nil {
+			continue
+		}
+
+		switch *tag.Key {
+		case domainTagKey:
+			settings.Domain = *tag.Value
+		case hostedZoneIdTagKey:
+			settings.HostedZoneId = *tag.Value
+		case ttlTagKey:
+			ttl, err := strconv.ParseInt(*tag.Value, 10, 64)
+			if err != nil || ttl <= 0 {
+				return fmt.Errorf("invalid ttl '%v'", *tag.Value)
+			}
+
+			settings.TTL = ttl
+		}
+	}
+
+	return nil
+}
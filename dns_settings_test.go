@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetDnsSettings_WithoutTags_ReturnsDefaults(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+
+	defaults := dnsSettings{Domain: "domain", TTL: 300}
+
+	result, err := getDnsSettings(ctx, mockedEcsApi, "cluster", ecsTypes.Task{}, false, defaults)
+
+	assert.Equal(t, defaults, result)
+	assert.Nil(t, err)
+
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func Test_GetDnsSettings_WithoutDomain_Error(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+
+	result, err := getDnsSettings(ctx, mockedEcsApi, "cluster", ecsTypes.Task{}, false, dnsSettings{TTL: 300})
+
+	assert.Equal(t, dnsSettings{}, result)
+	assert.EqualError(t, err, "no domain configured")
+
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func Test_GetDnsSettings_TaskTags_Ok(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+
+	task := ecsTypes.Task{
+		Tags: []ecsTypes.Tag{
+			{Key: aws.String(domainTagKey), Value: aws.String("task.example.com")},
+			{Key: aws.String(hostedZoneIdTagKey), Value: aws.String("zoneId")},
+			{Key: aws.String(ttlTagKey), Value: aws.String("60")},
+			{Key: aws.String("other"), Value: aws.String("value")},
+		},
+	}
+
+	result, err := getDnsSettings(ctx, mockedEcsApi, "cluster", task, false, dnsSettings{Domain: "domain", TTL: 300})
+
+	assert.Equal(t, dnsSettings{Domain: "task.example.com", HostedZoneId: "zoneId", TTL: 60}, result)
+	assert.Nil(t, err)
+
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func Test_GetDnsSettings_TaskTags_InvalidTTL(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+
+	task := ecsTypes.Task{
+		Tags: []ecsTypes.Tag{
+			{Key: aws.String(ttlTagKey), Value: aws.String("wadus")},
+		},
+	}
+
+	result, err := getDnsSettings(ctx, mockedEcsApi, "cluster", task, false, dnsSettings{Domain: "domain", TTL: 300})
+
+	assert.Equal(t, dnsSettings{}, result)
+	assert.EqualError(t, err, "error reading task tags: invalid ttl 'wadus'")
+
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func Test_GetDnsSettings_ServiceTags_DescribeServices_Error(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+
+	describeServicesInput := &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []string{"service"},
+		Include:  []ecsTypes.ServiceField{ecsTypes.ServiceFieldTags},
+	}
+
+	mockedEcsApi.On("DescribeServices", ctx, describeServicesInput).Return(nil, fmt.Errorf("some error"))
+
+	task := ecsTypes.Task{Group: aws.String("service:service")}
+
+	result, err := getDnsSettings(ctx, mockedEcsApi, "cluster", task, true, dnsSettings{Domain: "domain", TTL: 300})
+
+	assert.Equal(t, dnsSettings{}, result)
+	assert.EqualError(t, err, "error describing service 'service': some error")
+
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func Test_GetDnsSettings_ServiceTags_TaskTagsWin(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+
+	describeServicesInput := &ecs.DescribeServicesInput{
+		Cluster:  aws.String("cluster"),
+		Services: []string{"service"},
+		Include:  []ecsTypes.ServiceField{ecsTypes.ServiceFieldTags},
+	}
+
+	describeServicesOutput := &ecs.DescribeServicesOutput{
+		Services: []ecsTypes.Service{
+			{
+				Tags: []ecsTypes.Tag{
+					{Key: aws.String(domainTagKey), Value: aws.String("service.example.com")},
+					{Key: aws.String(ttlTagKey), Value: aws.String("120")},
+				},
+			},
+		},
+	}
+
+	mockedEcsApi.On("DescribeServices", ctx, describeServicesInput).Return(describeServicesOutput, nil)
+
+	task := ecsTypes.Task{
+		Group: aws.String("service:service"),
+		Tags: []ecsTypes.Tag{
+			{Key: aws.String(domainTagKey), Value: aws.String("task.example.com")},
+		},
+	}
+
+	result, err := getDnsSettings(ctx, mockedEcsApi, "cluster", task, true, dnsSettings{Domain: "domain", TTL: 300})
+
+	assert.Equal(t, dnsSettings{Domain: "task.example.com", TTL: 120}, result)
+	assert.Nil(t, err)
+
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func Test_GetDnsSettings_ServiceTags_NotAServiceTask(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+
+	task := ecsTypes.Task{Group: aws.String("family:someFamily")}
+
+	result, err := getDnsSettings(ctx, mockedEcsApi, "cluster", task, true, dnsSettings{Domain: "domain", TTL: 300})
+
+	assert.Equal(t, dnsSettings{Domain: "domain", TTL: 300}, result)
+	assert.Nil(t, err)
+
+	mockedEcsApi.AssertExpectations(t)
+}
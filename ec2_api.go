@@ -10,6 +10,19 @@ import (
 
 type Ec2Api interface {
 	DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error)
+	CreateTags(ctx context.Context, params *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error)
+	DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error)
+	AssociateAddress(ctx context.Context, params *ec2.AssociateAddressInput) (*ec2.AssociateAddressOutput, error)
+	DisassociateAddress(ctx context.Context, params *ec2.DisassociateAddressInput) (*ec2.DisassociateAddressOutput, error)
+	DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error)
+	DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error)
+	DescribeManagedPrefixLists(ctx context.Context, params *ec2.DescribeManagedPrefixListsInput) (*ec2.DescribeManagedPrefixListsOutput, error)
+	GetManagedPrefixListEntries(ctx context.Context, params *ec2.GetManagedPrefixListEntriesInput) (*ec2.GetManagedPrefixListEntriesOutput, error)
+	ModifyManagedPrefixList(ctx context.Context, params *ec2.ModifyManagedPrefixListInput) (*ec2.ModifyManagedPrefixListOutput, error)
+	DescribeSecurityGroupRules(ctx context.Context, params *ec2.DescribeSecurityGroupRulesInput) (*ec2.DescribeSecurityGroupRulesOutput, error)
+	AuthorizeSecurityGroupIngress(ctx context.Context, params *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error)
+	RevokeSecurityGroupIngress(ctx context.Context, params *ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error)
+	UpdateSecurityGroupRuleDescriptionsIngress(ctx context.Context, params *ec2.UpdateSecurityGroupRuleDescriptionsIngressInput) (*ec2.UpdateSecurityGroupRuleDescriptionsIngressOutput, error)
 }
 
 type AwsEc2Api struct {
@@ -26,6 +39,58 @@ func (a *AwsEc2Api) DescribeNetworkInterfaces(ctx context.Context, params *ec2.D
 	return a.ec2Client.DescribeNetworkInterfaces(ctx, params)
 }
 
+func (a *AwsEc2Api) CreateTags(ctx context.Context, params *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	return a.ec2Client.CreateTags(ctx, params)
+}
+
+func (a *AwsEc2Api) DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	return a.ec2Client.DescribeAddresses(ctx, params)
+}
+
+func (a *AwsEc2Api) AssociateAddress(ctx context.Context, params *ec2.AssociateAddressInput) (*ec2.AssociateAddressOutput, error) {
+	return a.ec2Client.AssociateAddress(ctx, params)
+}
+
+func (a *AwsEc2Api) DisassociateAddress(ctx context.Context, params *ec2.DisassociateAddressInput) (*ec2.DisassociateAddressOutput, error) {
+	return a.ec2Client.DisassociateAddress(ctx, params)
+}
+
+func (a *AwsEc2Api) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error) {
+	return a.ec2Client.DescribeRouteTables(ctx, params)
+}
+
+func (a *AwsEc2Api) DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error) {
+	return a.ec2Client.DescribeNatGateways(ctx, params)
+}
+
+func (a *AwsEc2Api) DescribeManagedPrefixLists(ctx context.Context, params *ec2.DescribeManagedPrefixListsInput) (*ec2.DescribeManagedPrefixListsOutput, error) {
+	return a.ec2Client.DescribeManagedPrefixLists(ctx, params)
+}
+
+func (a *AwsEc2Api) GetManagedPrefixListEntries(ctx context.Context, params *ec2.GetManagedPrefixListEntriesInput) (*ec2.GetManagedPrefixListEntriesOutput, error) {
+	return a.ec2Client.GetManagedPrefixListEntries(ctx, params)
+}
+
+func (a *AwsEc2Api) ModifyManagedPrefixList(ctx context.Context, params *ec2.ModifyManagedPrefixListInput) (*ec2.ModifyManagedPrefixListOutput, error) {
+	return a.ec2Client.ModifyManagedPrefixList(ctx, params)
+}
+
+func (a *AwsEc2Api) DescribeSecurityGroupRules(ctx context.Context, params *ec2.DescribeSecurityGroupRulesInput) (*ec2.DescribeSecurityGroupRulesOutput, error) {
+	return a.ec2Client.DescribeSecurityGroupRules(ctx, params)
+}
+
+func (a *AwsEc2Api) AuthorizeSecurityGroupIngress(ctx context.Context, params *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	return a.ec2Client.AuthorizeSecurityGroupIngress(ctx, params)
+}
+
+func (a *AwsEc2Api) RevokeSecurityGroupIngress(ctx context.Context, params *ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	return a.ec2Client.RevokeSecurityGroupIngress(ctx, params)
+}
+
+func (a *AwsEc2Api) UpdateSecurityGroupRuleDescriptionsIngress(ctx context.Context, params *ec2.UpdateSecurityGroupRuleDescriptionsIngressInput) (*ec2.UpdateSecurityGroupRuleDescriptionsIngressOutput, error) {
+	return a.ec2Client.UpdateSecurityGroupRuleDescriptionsIngress(ctx, params)
+}
+
 type MockedEc2Api struct {
 	mock.Mock
 }
@@ -43,3 +108,133 @@ func (m *MockedEc2Api) DescribeNetworkInterfaces(ctx context.Context, params *ec
 
 	return args.Get(0).(*ec2.DescribeNetworkInterfacesOutput), args.Error(1)
 }
+
+func (m *MockedEc2Api) CreateTags(ctx context.Context, params *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.CreateTagsOutput), args.Error(1)
+}
+
+func (m *MockedEc2Api) DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.DescribeAddressesOutput), args.Error(1)
+}
+
+func (m *MockedEc2Api) AssociateAddress(ctx context.Context, params *ec2.AssociateAddressInput) (*ec2.AssociateAddressOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.AssociateAddressOutput), args.Error(1)
+}
+
+func (m *MockedEc2Api) DisassociateAddress(ctx context.Context, params *ec2.DisassociateAddressInput) (*ec2.DisassociateAddressOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.DisassociateAddressOutput), args.Error(1)
+}
+
+func (m *MockedEc2Api) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.DescribeRouteTablesOutput), args.Error(1)
+}
+
+func (m *MockedEc2Api) DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.DescribeNatGatewaysOutput), args.Error(1)
+}
+
+func (m *MockedEc2Api) DescribeManagedPrefixLists(ctx context.Context, params *ec2.DescribeManagedPrefixListsInput) (*ec2.DescribeManagedPrefixListsOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.DescribeManagedPrefixListsOutput), args.Error(1)
+}
+
+func (m *MockedEc2Api) GetManagedPrefixListEntries(ctx context.Context, params *ec2.GetManagedPrefixListEntriesInput) (*ec2.GetManagedPrefixListEntriesOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.GetManagedPrefixListEntriesOutput), args.Error(1)
+}
+
+func (m *MockedEc2Api) ModifyManagedPrefixList(ctx context.Context, params *ec2.ModifyManagedPrefixListInput) (*ec2.ModifyManagedPrefixListOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.ModifyManagedPrefixListOutput), args.Error(1)
+}
+
+func (m *MockedEc2Api) DescribeSecurityGroupRules(ctx context.Context, params *ec2.DescribeSecurityGroupRulesInput) (*ec2.DescribeSecurityGroupRulesOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.DescribeSecurityGroupRulesOutput), args.Error(1)
+}
+
+func (m *MockedEc2Api) AuthorizeSecurityGroupIngress(ctx context.Context, params *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.AuthorizeSecurityGroupIngressOutput), args.Error(1)
+}
+
+func (m *MockedEc2Api) RevokeSecurityGroupIngress(ctx context.Context, params *ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.RevokeSecurityGroupIngressOutput), args.Error(1)
+}
+
+func (m *MockedEc2Api) UpdateSecurityGroupRuleDescriptionsIngress(ctx context.Context, params *ec2.UpdateSecurityGroupRuleDescriptionsIngressInput) (*ec2.UpdateSecurityGroupRuleDescriptionsIngressOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ec2.UpdateSecurityGroupRuleDescriptionsIngressOutput), args.Error(1)
+}
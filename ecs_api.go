@@ -10,6 +10,9 @@ import (
 
 type EcsApi interface {
 	DescribeTasks(ctx context.Context, params *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error)
+	DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error)
+	TagResource(ctx context.Context, params *ecs.TagResourceInput) (*ecs.TagResourceOutput, error)
+	ListTasks(ctx context.Context, params *ecs.ListTasksInput) (*ecs.ListTasksOutput, error)
 }
 
 type AwsEcsApi struct {
@@ -26,6 +29,18 @@ func (a *AwsEcsApi) DescribeTasks(ctx context.Context, params *ecs.DescribeTasks
 	return a.ecsClient.DescribeTasks(ctx, params)
 }
 
+func (a *AwsEcsApi) DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	return a.ecsClient.DescribeServices(ctx, params)
+}
+
+func (a *AwsEcsApi) TagResource(ctx context.Context, params *ecs.TagResourceInput) (*ecs.TagResourceOutput, error) {
+	return a.ecsClient.TagResource(ctx, params)
+}
+
+func (a *AwsEcsApi) ListTasks(ctx context.Context, params *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	return a.ecsClient.ListTasks(ctx, params)
+}
+
 type MockedEcsApi struct {
 	mock.Mock
 }
@@ -43,3 +58,33 @@ func (m *MockedEcsApi) DescribeTasks(ctx context.Context, params *ecs.DescribeTa
 
 	return args.Get(0).(*ecs.DescribeTasksOutput), args.Error(1)
 }
+
+func (m *MockedEcsApi) DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ecs.DescribeServicesOutput), args.Error(1)
+}
+
+func (m *MockedEcsApi) TagResource(ctx context.Context, params *ecs.TagResourceInput) (*ecs.TagResourceOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ecs.TagResourceOutput), args.Error(1)
+}
+
+func (m *MockedEcsApi) ListTasks(ctx context.Context, params *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*ecs.ListTasksOutput), args.Error(1)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+const defaultRouteCidr = "0.0.0.0/0"
+
+// errNoPublicIp is returned for the enis of tasks in private subnets.
+var errNoPublicIp = errors.New("no public ip")
+
+// getEgressIpFromTaskEni returns the public ip of the NAT gateway the task
+// goes out to the internet through.
+func getEgressIpFromTaskEni(ctx context.Context, ec2Api Ec2Api, taskEni string) (string, error) {
+	describeNetworkInterfacesOutput, err := ec2Api.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []string{taskEni},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error describing network interface with id '%v': %v", taskEni, err)
+	}
+
+	networkInterface := describeNetworkInterfacesOutput.NetworkInterfaces[0]
+	subnetId := aws.ToString(networkInterface.SubnetId)
+
+	natGatewayId, err := getSubnetNatGatewayId(ctx, ec2Api, subnetId, aws.ToString(networkInterface.VpcId))
+	if err != nil {
+		return "", err
+	}
+
+	if len(natGatewayId) == 0 {
+		return "", fmt.Errorf("the default route of subnet '%v' doesn't go through a nat gateway", subnetId)
+	}
+
+	publicIps, err := getNatGatewayPublicIps(ctx, ec2Api, []string{natGatewayId})
+	if err != nil {
+		return "", err
+	}
+
+	if len(publicIps) == 0 {
+		return "", fmt.Errorf("nat gateway '%v' has no public ip", natGatewayId)
+	}
+
+	log.Printf("Egress ip: %v (nat gateway '%v')\n", publicIps[0], natGatewayId)
+
+	return publicIps[0], nil
+}
+
+// getEgressIpsFromTaskEnis returns the public ips of the NAT gateways the
+// enis go out to the internet through, looking up each subnet once. Enis in
+// subnets without a NAT gateway are left out.
+func getEgressIpsFromTaskEnis(ctx context.Context, ec2Api Ec2Api, taskEnis []string) ([]string, error) {
+	networkInterfaces, err := describeTaskEnis(ctx, ec2Api, taskEnis)
+	if err != nil {
+		return nil, err
+	}
+
+	subnetVpcs := map[string]string{}
+	for _, networkInterface := range networkInterfaces {
+		subnetVpcs[aws.ToString(networkInterface.SubnetId)] = aws.ToString(networkInterface.VpcId)
+	}
+
+	subnetIds := []string{}
+	for subnetId := range subnetVpcs {
+		subnetIds = append(subnetIds, subnetId)
+	}
+	sort.Strings(subnetIds)
+
+	natGatewayIds := []string{}
+	for _, subnetId := range subnetIds {
+		natGatewayId, err := getSubnetNatGatewayId(ctx, ec2Api, subnetId, subnetVpcs[subnetId])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(natGatewayId) > 0 && !containsString(natGatewayIds, natGatewayId) {
+			natGatewayIds = append(natGatewayIds, natGatewayId)
+		}
+	}
+
+	if len(natGatewayIds) == 0 {
+		return []string{}, nil
+	}
+
+	return getNatGatewayPublicIps(ctx, ec2Api, natGatewayIds)
+}
+
+// getSubnetNatGatewayId returns the NAT gateway of the default route of the
+// subnet, or an empty id when the default route goes elsewhere.
+func getSubnetNatGatewayId(ctx context.Context, ec2Api Ec2Api, subnetId string, vpcId string) (string, error) {
+	routeTable, err := getSubnetRouteTable(ctx, ec2Api, subnetId, vpcId)
+	if err != nil {
+		return "", err
+	}
+
+	natGatewayId := ""
+	for _, route := range routeTable.Routes {
+		if aws.ToString(route.DestinationCidrBlock) == defaultRouteCidr && route.NatGatewayId != nil {
+			natGatewayId = *route.NatGatewayId
+		}
+	}
+
+	return natGatewayId, nil
+}
+
+func getNatGatewayPublicIps(ctx context.Context, ec2Api Ec2Api, natGatewayIds []string) ([]string, error) {
+	describeNatGatewaysOutput, err := ec2Api.DescribeNatGateways(ctx, &ec2.DescribeNatGatewaysInput{
+		NatGatewayIds: natGatewayIds,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing nat gateways with ids '%v': %v", strings.Join(natGatewayIds, ","), err)
+	}
+
+	publicIps := []string{}
+	for _, natGateway := range describeNatGatewaysOutput.NatGateways {
+		for _, address := range natGateway.NatGatewayAddresses {
+			if address.PublicIp != nil {
+				publicIps = append(publicIps, *address.PublicIp)
+			}
+		}
+	}
+
+	return publicIps, nil
+}
+
+// getSubnetRouteTable returns the route table associated to the subnet or,
+// when there is none, the main route table of the vpc.
+func getSubnetRouteTable(ctx context.Context, ec2Api Ec2Api, subnetId string, vpcId string) (ec2Types.RouteTable, error) {
+	filters := [][]ec2Types.Filter{
+		{{Name: aws.String("association.subnet-id"), Values: []string{subnetId}}},
+		{{Name: aws.String("vpc-id"), Values: []string{vpcId}}, {Name: aws.String("association.main"), Values: []string{"true"}}},
+	}
+
+	for _, filter := range filters {
+		describeRouteTablesOutput, err := ec2Api.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{Filters: filter})
+		if err != nil {
+			return ec2Types.RouteTable{}, fmt.Errorf("error describing the route tables of subnet '%v': %v", subnetId, err)
+		}
+
+		if len(describeRouteTablesOutput.RouteTables) > 0 {
+			return describeRouteTablesOutput.RouteTables[0], nil
+		}
+	}
+
+	return ec2Types.RouteTable{}, fmt.Errorf("no route table found for subnet '%v'", subnetId)
+}
+
+// planEgressIp plans adding the ip to the egress record. The tasks behind
+// every NAT gateway share that record, so ips are only added here; the gc
+// removes the ips of the NAT gateways no running task goes out through anymore.
+func planEgressIp(ctx context.Context, dnsProvider DNSProvider, settings dnsSettings, egressIp string) (recordPlan, error) {
+	plan, err := dnsProvider.Plan(ctx, settings, []string{egressIp})
+	if err != nil || plan.Action == planActionCreate {
+		return plan, err
+	}
+
+	egressIps := plan.OldIps
+	if !containsString(egressIps, egressIp) {
+		egressIps = append(append([]string{}, egressIps...), egressIp)
+	}
+
+	return newRecordPlan(settings, egressIps, true, plan.OldIps, plan.OldTTL), nil
+}
+
+// publishEgressIp adds the ip without replacing the whole record, so the ip of
+// a task behind another NAT gateway starting at the same time isn't dropped.
+func publishEgressIp(ctx context.Context, dnsProvider DNSProvider, settings dnsSettings, egressIp string) (string, error) {
+	return dnsProvider.AddIp(ctx, settings, egressIp)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func describePrivateEni(mockedEc2Api *MockedEc2Api) {
+	input := &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []string{"eni-1234"}}
+	output := &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []ec2Types.NetworkInterface{{SubnetId: aws.String("subnet-1"), VpcId: aws.String("vpc-1")}},
+	}
+	mockedEc2Api.On("DescribeNetworkInterfaces", context.TODO(), input).Return(output, nil)
+}
+
+func subnetRouteTablesInput() *ec2.DescribeRouteTablesInput {
+	return &ec2.DescribeRouteTablesInput{
+		Filters: []ec2Types.Filter{{Name: aws.String("association.subnet-id"), Values: []string{"subnet-1"}}},
+	}
+}
+
+func natRoutes(natGatewayId string) []ec2Types.Route {
+	return []ec2Types.Route{
+		{DestinationCidrBlock: aws.String("10.0.0.0/16"), GatewayId: aws.String("local")},
+		{DestinationCidrBlock: aws.String("0.0.0.0/0"), NatGatewayId: aws.String(natGatewayId)},
+	}
+}
+
+func describeNatGateway(mockedEc2Api *MockedEc2Api, natGatewayId string, publicIp string) {
+	input := &ec2.DescribeNatGatewaysInput{NatGatewayIds: []string{natGatewayId}}
+	output := &ec2.DescribeNatGatewaysOutput{
+		NatGateways: []ec2Types.NatGateway{{NatGatewayAddresses: []ec2Types.NatGatewayAddress{{PublicIp: aws.String(publicIp)}}}},
+	}
+	mockedEc2Api.On("DescribeNatGateways", context.TODO(), input).Return(output, nil).Once()
+}
+
+func Test_GetEgressIpFromTaskEni(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePrivateEni(mockedEc2Api)
+	mockedEc2Api.On("DescribeRouteTables", context.TODO(), subnetRouteTablesInput()).Return(&ec2.DescribeRouteTablesOutput{RouteTables: []ec2Types.RouteTable{{Routes: natRoutes("nat-1")}}}, nil).Once()
+	describeNatGateway(mockedEc2Api, "nat-1", "3.3.3.3")
+
+	result, err := getEgressIpFromTaskEni(context.TODO(), mockedEc2Api, "eni-1234")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "3.3.3.3", result)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_GetEgressIpFromTaskEni_MainRouteTable(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePrivateEni(mockedEc2Api)
+	mockedEc2Api.On("DescribeRouteTables", context.TODO(), subnetRouteTablesInput()).Return(&ec2.DescribeRouteTablesOutput{}, nil).Once()
+	mainRouteTableInput := &ec2.DescribeRouteTablesInput{
+		Filters: []ec2Types.Filter{
+			{Name: aws.String("vpc-id"), Values: []string{"vpc-1"}},
+			{Name: aws.String("association.main"), Values: []string{"true"}},
+		},
+	}
+	mockedEc2Api.On("DescribeRouteTables", context.TODO(), mainRouteTableInput).Return(&ec2.DescribeRouteTablesOutput{RouteTables: []ec2Types.RouteTable{{Routes: natRoutes("nat-2")}}}, nil).Once()
+	describeNatGateway(mockedEc2Api, "nat-2", "4.4.4.4")
+
+	result, err := getEgressIpFromTaskEni(context.TODO(), mockedEc2Api, "eni-1234")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "4.4.4.4", result)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_GetEgressIpFromTaskEni_InternetGateway(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePrivateEni(mockedEc2Api)
+	routes := []ec2Types.Route{{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: aws.String("igw-1")}}
+	mockedEc2Api.On("DescribeRouteTables", context.TODO(), subnetRouteTablesInput()).Return(&ec2.DescribeRouteTablesOutput{RouteTables: []ec2Types.RouteTable{{Routes: routes}}}, nil).Once()
+
+	_, err := getEgressIpFromTaskEni(context.TODO(), mockedEc2Api, "eni-1234")
+
+	assert.EqualError(t, err, "the default route of subnet 'subnet-1' doesn't go through a nat gateway")
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_GetPublicIpFromTaskEni_PrivateSubnet(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePrivateEni(mockedEc2Api)
+
+	_, err := getPublicIpFromTaskEni(context.TODO(), mockedEc2Api, "eni-1234")
+
+	assert.True(t, errors.Is(err, errNoPublicIp))
+}
+
+func Test_PublishEgressIp_AddsToSharedRecord(t *testing.T) {
+	provider, statePath := newSampleDnsPlugin(t)
+	settings := dnsSettings{Domain: "egress.example.com", HostedZoneId: "zoneId", TTL: 300}
+
+	ioutil.WriteFile(statePath, []byte(`{"zoneId/egress.example.com":{"version":1,"values":["3.3.3.3"],"ttl":300}}`), 0644)
+
+	outcome, err := publishEgressIp(context.TODO(), provider, settings, "3.3.3.3")
+
+	assert.Nil(t, err)
+	assert.Equal(t, publishOutcomeUnchanged, outcome)
+
+	outcome, err = publishEgressIp(context.TODO(), provider, settings, "4.4.4.4")
+
+	assert.Nil(t, err)
+	assert.Equal(t, publishOutcomeUpdated, outcome)
+
+	plan, err := provider.Plan(context.TODO(), settings, []string{"3.3.3.3", "4.4.4.4"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, planActionNoop, plan.Action)
+}
+
+func Test_PublishEgressIp_RetriesConcurrentChange(t *testing.T) {
+	provider, statePath := newSampleDnsPlugin(t)
+	settings := dnsSettings{Domain: "egress.example.com", HostedZoneId: "zoneId", TTL: 300}
+
+	ioutil.WriteFile(statePath, []byte(`{"zoneId/egress.example.com":{"version":1,"values":["3.3.3.3"],"ttl":300}}`), 0644)
+	t.Setenv(samplePluginConcurrentIpEnv, "5.5.5.5")
+
+	outcome, err := publishEgressIp(context.TODO(), provider, settings, "4.4.4.4")
+
+	assert.Nil(t, err)
+	assert.Equal(t, publishOutcomeUpdated, outcome)
+
+	plan, err := provider.Plan(context.TODO(), settings, []string{"3.3.3.3", "4.4.4.4", "5.5.5.5"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, planActionNoop, plan.Action)
+}
+
+func Test_PublishEgressIp_Route53_RetriesConcurrentChange(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	settings := dnsSettings{Domain: "domain", HostedZoneId: "zoneId", TTL: 60}
+
+	firstRecordSet := *aRecordSet("domain.", 60, []string{"3.3.3.3"})
+	changedRecordSet := *aRecordSet("domain.", 60, []string{"3.3.3.3", "5.5.5.5"})
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{firstRecordSet}}, nil).Once()
+
+	firstInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &firstRecordSet},
+				{Action: route53Types.ChangeActionCreate, ResourceRecordSet: aRecordSet("domain", 60, []string{"3.3.3.3", "4.4.4.4"})},
+			},
+		},
+		HostedZoneId: aws.String("zoneId"),
+	}
+	notFound := &smithy.GenericAPIError{Code: invalidChangeBatchErrorCode, Message: "not found"}
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, firstInput).Return(nil, notFound).Once()
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{changedRecordSet}}, nil).Once()
+
+	secondInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &changedRecordSet},
+				{Action: route53Types.ChangeActionCreate, ResourceRecordSet: aRecordSet("domain", 60, []string{"3.3.3.3", "5.5.5.5", "4.4.4.4"})},
+			},
+		},
+		HostedZoneId: aws.String("zoneId"),
+	}
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, secondInput).Return(&route53.ChangeResourceRecordSetsOutput{}, nil).Once()
+
+	outcome, err := publishEgressIp(ctx, NewRoute53DNSProvider(mockedRoute53Api), settings, "4.4.4.4")
+
+	assert.Nil(t, err)
+	assert.Equal(t, publishOutcomeUpdated, outcome)
+	mockedRoute53Api.AssertExpectations(t)
+}
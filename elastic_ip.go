@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+const alreadyAssociatedErrorCode = "Resource.AlreadyAssociated"
+
+type elasticIp struct {
+	AllocationId  string
+	AssociationId string
+	PublicIp      string
+}
+
+// ElasticIpPool hands out the Elastic IPs tagged with the pool tag, so the
+// tasks get stable public addresses.
+type ElasticIpPool struct {
+	ec2Api   Ec2Api
+	tagKey   string
+	tagValue string
+	shuffle  func(addresses []ec2Types.Address)
+}
+
+// NewElasticIpPool receives the pool tag as key=value.
+func NewElasticIpPool(ec2Api Ec2Api, tag string) (*ElasticIpPool, error) {
+	parts := strings.SplitN(tag, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return nil, fmt.Errorf("invalid elastic ip pool tag '%v', expected key=value", tag)
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	return &ElasticIpPool{
+		ec2Api:   ec2Api,
+		tagKey:   parts[0],
+		tagValue: parts[1],
+		shuffle: func(addresses []ec2Types.Address) {
+			random.Shuffle(len(addresses), func(i, j int) {
+				addresses[i], addresses[j] = addresses[j], addresses[i]
+			})
+		},
+	}, nil
+}
+
+// Claim associates a free Elastic IP of the pool to the eni. The association
+// doesn't allow reassociation, so when several tasks race for the same address
+// only one gets it and the others move on to the next one. The free addresses
+// are tried in random order to make those races unlikely.
+func (p *ElasticIpPool) Claim(ctx context.Context, eni string) (elasticIp, error) {
+	describeAddressesOutput, err := p.ec2Api.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+		Filters: []ec2Types.Filter{
+			{
+				Name:   aws.String("tag:" + p.tagKey),
+				Values: []string{p.tagValue},
+			},
+		},
+	})
+	if err != nil {
+		return elasticIp{}, fmt.Errorf("error describing the elastic ips of the pool '%v=%v': %v", p.tagKey, p.tagValue, err)
+	}
+
+	free := []ec2Types.Address{}
+	for _, address := range describeAddressesOutput.Addresses {
+		// the sidecar was restarted and the task already has one
+		if aws.ToString(address.NetworkInterfaceId) == eni {
+			log.Printf("Elastic ip '%v' already associated to eni '%v'\n", aws.ToString(address.PublicIp), eni)
+
+			return elasticIp{AllocationId: aws.ToString(address.AllocationId), AssociationId: aws.ToString(address.AssociationId), PublicIp: aws.ToString(address.PublicIp)}, nil
+		}
+
+		if address.AssociationId == nil {
+			free = append(free, address)
+		}
+	}
+
+	p.shuffle(free)
+
+	for _, address := range free {
+		associateAddressOutput, err := p.ec2Api.AssociateAddress(ctx, &ec2.AssociateAddressInput{
+			AllocationId:       address.AllocationId,
+			NetworkInterfaceId: aws.String(eni),
+			AllowReassociation: aws.Bool(false),
+		})
+		if err != nil {
+			if awsErrorCode(err) == alreadyAssociatedErrorCode {
+				log.Printf("Elastic ip '%v' was claimed by another task, trying the next one\n", aws.ToString(address.PublicIp))
+				continue
+			}
+
+			return elasticIp{}, fmt.Errorf("error associating the elastic ip '%v' to eni '%v': %v", aws.ToString(address.PublicIp), eni, err)
+		}
+
+		log.Printf("Associated elastic ip '%v' to eni '%v'\n", aws.ToString(address.PublicIp), eni)
+
+		return elasticIp{AllocationId: aws.ToString(address.AllocationId), AssociationId: aws.ToString(associateAddressOutput.AssociationId), PublicIp: aws.ToString(address.PublicIp)}, nil
+	}
+
+	return elasticIp{}, fmt.Errorf("no free elastic ip in the pool '%v=%v'", p.tagKey, p.tagValue)
+}
+
+// Release disassociates the Elastic IP so it goes back to the pool.
+func (p *ElasticIpPool) Release(ctx context.Context, ip elasticIp) error {
+	_, err := p.ec2Api.DisassociateAddress(ctx, &ec2.DisassociateAddressInput{AssociationId: aws.String(ip.AssociationId)})
+	if err != nil {
+		return fmt.Errorf("error disassociating the elastic ip '%v': %v", ip.PublicIp, err)
+	}
+
+	log.Printf("Disassociated elastic ip '%v'\n", ip.PublicIp)
+
+	return nil
+}
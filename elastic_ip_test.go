@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestElasticIpPool(t *testing.T, ec2Api Ec2Api) *ElasticIpPool {
+	pool, err := NewElasticIpPool(ec2Api, "pool=partners")
+	assert.Nil(t, err)
+
+	pool.shuffle = func(addresses []ec2Types.Address) {}
+
+	return pool
+}
+
+func describePoolAddresses(mockedEc2Api *MockedEc2Api, addresses ...ec2Types.Address) {
+	input := &ec2.DescribeAddressesInput{
+		Filters: []ec2Types.Filter{{Name: aws.String("tag:pool"), Values: []string{"partners"}}},
+	}
+	mockedEc2Api.On("DescribeAddresses", context.TODO(), input).Return(&ec2.DescribeAddressesOutput{Addresses: addresses}, nil).Once()
+}
+
+func associateAddressInput(allocationId string) *ec2.AssociateAddressInput {
+	return &ec2.AssociateAddressInput{
+		AllocationId:       aws.String(allocationId),
+		NetworkInterfaceId: aws.String("eni-1234"),
+		AllowReassociation: aws.Bool(false),
+	}
+}
+
+func Test_ElasticIpPool_Claim(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePoolAddresses(mockedEc2Api,
+		ec2Types.Address{AllocationId: aws.String("eipalloc-1"), PublicIp: aws.String("1.1.1.1"), AssociationId: aws.String("eipassoc-other"), NetworkInterfaceId: aws.String("eni-other")},
+		ec2Types.Address{AllocationId: aws.String("eipalloc-2"), PublicIp: aws.String("2.2.2.2")},
+	)
+	mockedEc2Api.On("AssociateAddress", context.TODO(), associateAddressInput("eipalloc-2")).Return(&ec2.AssociateAddressOutput{AssociationId: aws.String("eipassoc-2")}, nil).Once()
+
+	result, err := newTestElasticIpPool(t, mockedEc2Api).Claim(context.TODO(), "eni-1234")
+
+	assert.Nil(t, err)
+	assert.Equal(t, elasticIp{AllocationId: "eipalloc-2", AssociationId: "eipassoc-2", PublicIp: "2.2.2.2"}, result)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_ElasticIpPool_Claim_LostRace(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePoolAddresses(mockedEc2Api,
+		ec2Types.Address{AllocationId: aws.String("eipalloc-1"), PublicIp: aws.String("1.1.1.1")},
+		ec2Types.Address{AllocationId: aws.String("eipalloc-2"), PublicIp: aws.String("2.2.2.2")},
+	)
+	alreadyAssociated := &smithy.GenericAPIError{Code: "Resource.AlreadyAssociated", Message: "already associated"}
+	mockedEc2Api.On("AssociateAddress", context.TODO(), associateAddressInput("eipalloc-1")).Return(nil, alreadyAssociated).Once()
+	mockedEc2Api.On("AssociateAddress", context.TODO(), associateAddressInput("eipalloc-2")).Return(&ec2.AssociateAddressOutput{AssociationId: aws.String("eipassoc-2")}, nil).Once()
+
+	result, err := newTestElasticIpPool(t, mockedEc2Api).Claim(context.TODO(), "eni-1234")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "2.2.2.2", result.PublicIp)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_ElasticIpPool_Claim_AlreadyAssociatedToEni(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePoolAddresses(mockedEc2Api,
+		ec2Types.Address{AllocationId: aws.String("eipalloc-1"), PublicIp: aws.String("1.1.1.1"), AssociationId: aws.String("eipassoc-1"), NetworkInterfaceId: aws.String("eni-1234")},
+	)
+
+	result, err := newTestElasticIpPool(t, mockedEc2Api).Claim(context.TODO(), "eni-1234")
+
+	assert.Nil(t, err)
+	assert.Equal(t, elasticIp{AllocationId: "eipalloc-1", AssociationId: "eipassoc-1", PublicIp: "1.1.1.1"}, result)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_ElasticIpPool_Claim_PoolExhausted(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePoolAddresses(mockedEc2Api,
+		ec2Types.Address{AllocationId: aws.String("eipalloc-1"), PublicIp: aws.String("1.1.1.1"), AssociationId: aws.String("eipassoc-other")},
+	)
+
+	_, err := newTestElasticIpPool(t, mockedEc2Api).Claim(context.TODO(), "eni-1234")
+
+	assert.EqualError(t, err, "no free elastic ip in the pool 'pool=partners'")
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_ElasticIpPool_Claim_AssociateError(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	describePoolAddresses(mockedEc2Api, ec2Types.Address{AllocationId: aws.String("eipalloc-1"), PublicIp: aws.String("1.1.1.1")})
+	mockedEc2Api.On("AssociateAddress", context.TODO(), associateAddressInput("eipalloc-1")).Return(nil, fmt.Errorf("some error")).Once()
+
+	_, err := newTestElasticIpPool(t, mockedEc2Api).Claim(context.TODO(), "eni-1234")
+
+	assert.EqualError(t, err, "error associating the elastic ip '1.1.1.1' to eni 'eni-1234': some error")
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_ElasticIpPool_Release(t *testing.T) {
+	mockedEc2Api := NewMockedEc2Api()
+
+	mockedEc2Api.On("DisassociateAddress", context.TODO(), &ec2.DisassociateAddressInput{AssociationId: aws.String("eipassoc-1")}).Return(&ec2.DisassociateAddressOutput{}, nil).Once()
+
+	err := newTestElasticIpPool(t, mockedEc2Api).Release(context.TODO(), elasticIp{AssociationId: "eipassoc-1", PublicIp: "1.1.1.1"})
+
+	assert.Nil(t, err)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_NewElasticIpPool_InvalidTag(t *testing.T) {
+	_, err := NewElasticIpPool(NewMockedEc2Api(), "pool")
+
+	assert.EqualError(t, err, "invalid elastic ip pool tag 'pool', expected key=value")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/stretchr/testify/mock"
+)
+
+type Elbv2Api interface {
+	RegisterTargets(ctx context.Context, params *elasticloadbalancingv2.RegisterTargetsInput) (*elasticloadbalancingv2.RegisterTargetsOutput, error)
+	DeregisterTargets(ctx context.Context, params *elasticloadbalancingv2.DeregisterTargetsInput) (*elasticloadbalancingv2.DeregisterTargetsOutput, error)
+	DescribeTargetHealth(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetHealthInput) (*elasticloadbalancingv2.DescribeTargetHealthOutput, error)
+}
+
+type AwsElbv2Api struct {
+	elbv2Client *elasticloadbalancingv2.Client
+}
+
+func NewAwsElbv2Api(cfg aws.Config) *AwsElbv2Api {
+	elbv2Client := elasticloadbalancingv2.NewFromConfig(cfg)
+
+	return &AwsElbv2Api{elbv2Client: elbv2Client}
+}
+
+func (a *AwsElbv2Api) RegisterTargets(ctx context.Context, params *elasticloadbalancingv2.RegisterTargetsInput) (*elasticloadbalancingv2.RegisterTargetsOutput, error) {
+	return a.elbv2Client.RegisterTargets(ctx, params)
+}
+
+func (a *AwsElbv2Api) DeregisterTargets(ctx context.Context, params *elasticloadbalancingv2.DeregisterTargetsInput) (*elasticloadbalancingv2.DeregisterTargetsOutput, error) {
+	return a.elbv2Client.DeregisterTargets(ctx, params)
+}
+
+func (a *AwsElbv2Api) DescribeTargetHealth(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetHealthInput) (*elasticloadbalancingv2.DescribeTargetHealthOutput, error) {
+	return a.elbv2Client.DescribeTargetHealth(ctx, params)
+}
+
+type MockedElbv2Api struct {
+	mock.Mock
+}
+
+func NewMockedElbv2Api() *MockedElbv2Api {
+	return &MockedElbv2Api{}
+}
+
+func (m *MockedElbv2Api) RegisterTargets(ctx context.Context, params *elasticloadbalancingv2.RegisterTargetsInput) (*elasticloadbalancingv2.RegisterTargetsOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*elasticloadbalancingv2.RegisterTargetsOutput), args.Error(1)
+}
+
+func (m *MockedElbv2Api) DeregisterTargets(ctx context.Context, params *elasticloadbalancingv2.DeregisterTargetsInput) (*elasticloadbalancingv2.DeregisterTargetsOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*elasticloadbalancingv2.DeregisterTargetsOutput), args.Error(1)
+}
+
+func (m *MockedElbv2Api) DescribeTargetHealth(ctx context.Context, params *elasticloadbalancingv2.DescribeTargetHealthInput) (*elasticloadbalancingv2.DescribeTargetHealthOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*elasticloadbalancingv2.DescribeTargetHealthOutput), args.Error(1)
+}
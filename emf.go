@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	defaultEmfNamespace = "EcsSidecar"
+
+	emfUnitMilliseconds = "Milliseconds"
+	emfUnitCount        = "Count"
+)
+
+type emfDimensions struct {
+	Cluster string
+	Service string
+	Domain  string
+}
+
+type emfMetric struct {
+	Name string
+	Unit string
+}
+
+type emfMetricDirective struct {
+	Namespace  string
+	Dimensions [][]string
+	Metrics    []emfMetric
+}
+
+type emfMetadata struct {
+	Timestamp         int64
+	CloudWatchMetrics []emfMetricDirective
+}
+
+// EmfEmitter writes metrics as CloudWatch Embedded Metric Format json lines,
+// which the awslogs driver turns into CloudWatch metrics.
+type EmfEmitter struct {
+	mu         sync.Mutex
+	w          io.Writer
+	namespace  string
+	dimensions emfDimensions
+	now        func() time.Time
+}
+
+func NewEmfEmitter(w io.Writer, namespace string, dimensions emfDimensions) *EmfEmitter {
+	return &EmfEmitter{
+		w:          w,
+		namespace:  namespace,
+		dimensions: dimensions,
+		now:        time.Now,
+	}
+}
+
+// SetDimensions replaces the dimensions once the service and domain of the
+// task are known.
+func (e *EmfEmitter) SetDimensions(dimensions emfDimensions) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.dimensions = dimensions
+}
+
+func (e *EmfEmitter) EmitLatency(step string, latency time.Duration) {
+	e.emit(step, nil, []emfMetric{{Name: "Latency", Unit: emfUnitMilliseconds}}, map[string]interface{}{
+		"Latency": float64(latency) / float64(time.Millisecond),
+	})
+}
+
+// EmitPublish records the outcome of a publish. IpChanges is 1 when the
+// record was created or changed.
+func (e *EmfEmitter) EmitPublish(outcome string) {
+	failed := 0
+	if outcome == publishOutcomeFailed {
+		failed = 1
+	}
+
+	ipChanges := 0
+	if outcome == publishOutcomeCreated || outcome == publishOutcomeUpdated {
+		ipChanges = 1
+	}
+
+	e.emit("", map[string]string{"Outcome": outcome}, []emfMetric{
+		{Name: "Publishes", Unit: emfUnitCount},
+		{Name: "PublishErrors", Unit: emfUnitCount},
+		{Name: "IpChanges", Unit: emfUnitCount},
+	}, map[string]interface{}{
+		"Publishes":     1,
+		"PublishErrors": failed,
+		"IpChanges":     ipChanges,
+	})
+}
+
+// emit writes one line. CloudWatch rejects empty dimension values, so the
+// empty ones are left out.
+func (e *EmfEmitter) emit(step string, properties map[string]string, metrics []emfMetric, values map[string]interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	record := map[string]interface{}{}
+	dimensionKeys := []string{}
+
+	for _, dimension := range []struct{ key, value string }{
+		{"Cluster", e.dimensions.Cluster},
+		{"Service", e.dimensions.Service},
+		{"Domain", e.dimensions.Domain},
+		{"Step", step},
+	} {
+		if len(dimension.value) > 0 {
+			dimensionKeys = append(dimensionKeys, dimension.key)
+			record[dimension.key] = dimension.value
+		}
+	}
+
+	for key, value := range properties {
+		record[key] = value
+	}
+
+	for key, value := range values {
+		record[key] = value
+	}
+
+	record["_aws"] = emfMetadata{
+		Timestamp: e.now().UnixNano() / int64(time.Millisecond),
+		CloudWatchMetrics: []emfMetricDirective{
+			{Namespace: e.namespace, Dimensions: [][]string{dimensionKeys}, Metrics: metrics},
+		},
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Error encoding the emf record: %v\n", err)
+		return
+	}
+
+	fmt.Fprintln(e.w, string(line))
+}
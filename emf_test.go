@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEmfEmitter(buf *bytes.Buffer, dimensions emfDimensions) *EmfEmitter {
+	emf := NewEmfEmitter(buf, defaultEmfNamespace, dimensions)
+	emf.now = func() time.Time {
+		return time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	}
+
+	return emf
+}
+
+func Test_EmfEmitter_EmitLatency(t *testing.T) {
+	var buf bytes.Buffer
+	emf := newTestEmfEmitter(&buf, emfDimensions{Cluster: "cluster", Service: "service", Domain: "app.example.com"})
+
+	emf.EmitLatency("DescribeTasks", 1500*time.Microsecond)
+
+	assert.Equal(t, `{"Cluster":"cluster","Domain":"app.example.com","Latency":1.5,"Service":"service","Step":"DescribeTasks","_aws":{"Timestamp":1646128800000,"CloudWatchMetrics":[{"Namespace":"EcsSidecar","Dimensions":[["Cluster","Service","Domain","Step"]],"Metrics":[{"Name":"Latency","Unit":"Milliseconds"}]}]}}`+"\n", buf.String())
+}
+
+func Test_EmfEmitter_EmitPublish(t *testing.T) {
+	var buf bytes.Buffer
+	emf := newTestEmfEmitter(&buf, emfDimensions{Cluster: "cluster", Service: "service", Domain: "app.example.com"})
+
+	emf.EmitPublish(publishOutcomeUpdated)
+
+	assert.Equal(t, `{"Cluster":"cluster","Domain":"app.example.com","IpChanges":1,"Outcome":"updated","PublishErrors":0,"Publishes":1,"Service":"service","_aws":{"Timestamp":1646128800000,"CloudWatchMetrics":[{"Namespace":"EcsSidecar","Dimensions":[["Cluster","Service","Domain"]],"Metrics":[{"Name":"Publishes","Unit":"Count"},{"Name":"PublishErrors","Unit":"Count"},{"Name":"IpChanges","Unit":"Count"}]}]}}`+"\n", buf.String())
+}
+
+func Test_EmfEmitter_EmitPublish_FailedWithoutService(t *testing.T) {
+	var buf bytes.Buffer
+	emf := newTestEmfEmitter(&buf, emfDimensions{Cluster: "cluster", Domain: "app.example.com"})
+
+	emf.EmitPublish(publishOutcomeFailed)
+
+	assert.Equal(t, `{"Cluster":"cluster","Domain":"app.example.com","IpChanges":0,"Outcome":"failed","PublishErrors":1,"Publishes":1,"_aws":{"Timestamp":1646128800000,"CloudWatchMetrics":[{"Namespace":"EcsSidecar","Dimensions":[["Cluster","Domain"]],"Metrics":[{"Name":"Publishes","Unit":"Count"},{"Name":"PublishErrors","Unit":"Count"},{"Name":"IpChanges","Unit":"Count"}]}]}}`+"\n", buf.String())
+}
+
+func Test_Metrics_EmitsEmf(t *testing.T) {
+	var buf bytes.Buffer
+	emf := newTestEmfEmitter(&buf, emfDimensions{Cluster: "cluster"})
+
+	metrics := NewMetrics(prometheus.NewRegistry(), emf)
+	metrics.RecordPublished("app.example.com", "1.1.1.1", publishOutcomeUnchanged)
+
+	assert.Equal(t, `{"Cluster":"cluster","IpChanges":0,"Outcome":"unchanged","PublishErrors":0,"Publishes":1,"_aws":{"Timestamp":1646128800000,"CloudWatchMetrics":[{"Namespace":"EcsSidecar","Dimensions":[["Cluster"]],"Metrics":[{"Name":"Publishes","Unit":"Count"},{"Name":"PublishErrors","Unit":"Count"},{"Name":"IpChanges","Unit":"Count"}]}]}}`+"\n", buf.String())
+}
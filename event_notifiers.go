@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgeTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snsTypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+const (
+	// recordEventSchemaVersion changes whenever a field of recordEvent is
+	// removed or changes its meaning. New fields don't change it.
+	recordEventSchemaVersion = "1"
+
+	eventBridgeSource     = "ecs-sidecar"
+	eventBridgeDetailType = "ECS Sidecar Record Event"
+
+	snsActionAttribute = "action"
+)
+
+// recordEvent is the versioned schema of the events sent to SNS and
+// EventBridge.
+type recordEvent struct {
+	Version      string    `json:"version"`
+	Action       string    `json:"action"`
+	Time         time.Time `json:"time"`
+	TaskArn      string    `json:"taskArn"`
+	Cluster      string    `json:"cluster"`
+	Record       string    `json:"record"`
+	HostedZoneId string    `json:"hostedZoneId"`
+	OldIp        string    `json:"oldIp"`
+	NewIp        string    `json:"newIp"`
+	Error        string    `json:"error,omitempty"`
+}
+
+func newRecordEvent(event lifecycleEvent) recordEvent {
+	return recordEvent{
+		Version:      recordEventSchemaVersion,
+		Action:       event.Type,
+		Time:         event.Time,
+		TaskArn:      event.TaskArn,
+		Cluster:      event.Cluster,
+		Record:       event.Record,
+		HostedZoneId: event.HostedZoneId,
+		OldIp:        event.OldIp,
+		NewIp:        event.NewIp,
+		Error:        event.Error,
+	}
+}
+
+// SnsNotifier publishes the events to a topic, with the action as a message
+// attribute so subscriptions can filter on it.
+type SnsNotifier struct {
+	api      SnsApi
+	topicArn string
+}
+
+func NewSnsNotifier(api SnsApi, topicArn string) *SnsNotifier {
+	return &SnsNotifier{api: api, topicArn: topicArn}
+}
+
+func (n *SnsNotifier) Notify(ctx context.Context, event lifecycleEvent) error {
+	message, err := json.Marshal(newRecordEvent(event))
+	if err != nil {
+		return err
+	}
+
+	input := &sns.PublishInput{
+		TopicArn: aws.String(n.topicArn),
+		Message:  aws.String(string(message)),
+		MessageAttributes: map[string]snsTypes.MessageAttributeValue{
+			snsActionAttribute: {DataType: aws.String("String"), StringValue: aws.String(event.Type)},
+		},
+	}
+
+	_, err = n.api.Publish(ctx, input)
+	if err != nil {
+		return fmt.Errorf("error publishing to the sns topic '%v': %v", n.topicArn, err)
+	}
+
+	return nil
+}
+
+// EventBridgeNotifier puts the events in a bus, with the task as the resource.
+type EventBridgeNotifier struct {
+	api     EventBridgeApi
+	busName string
+}
+
+func NewEventBridgeNotifier(api EventBridgeApi, busName string) *EventBridgeNotifier {
+	return &EventBridgeNotifier{api: api, busName: busName}
+}
+
+func (n *EventBridgeNotifier) Notify(ctx context.Context, event lifecycleEvent) error {
+	detail, err := json.Marshal(newRecordEvent(event))
+	if err != nil {
+		return err
+	}
+
+	input := &eventbridge.PutEventsInput{
+		Entries: []eventbridgeTypes.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String(n.busName),
+				Source:       aws.String(eventBridgeSource),
+				DetailType:   aws.String(eventBridgeDetailType),
+				Detail:       aws.String(string(detail)),
+				Resources:    []string{event.TaskArn},
+				Time:         aws.Time(event.Time),
+			},
+		},
+	}
+
+	output, err := n.api.PutEvents(ctx, input)
+	if err != nil {
+		return fmt.Errorf("error putting the event in the bus '%v': %v", n.busName, err)
+	}
+
+	if output.FailedEntryCount > 0 && len(output.Entries) > 0 {
+		entry := output.Entries[0]
+
+		return fmt.Errorf("error putting the event in the bus '%v': %v %v", n.busName, aws.ToString(entry.ErrorCode), aws.ToString(entry.ErrorMessage))
+	}
+
+	return nil
+}
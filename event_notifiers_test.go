@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgeTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snsTypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testRecordEventJson = `{"version":"1","action":"changed","time":"2022-03-01T10:00:00Z","taskArn":"taskArn","cluster":"cluster","record":"app.example.com","hostedZoneId":"zoneId","oldIp":"1.1.1.1","newIp":"2.2.2.2"}`
+
+func Test_SnsNotifier_Notify(t *testing.T) {
+	mockedSnsApi := NewMockedSnsApi()
+
+	input := &sns.PublishInput{
+		TopicArn: aws.String("topicArn"),
+		Message:  aws.String(testRecordEventJson),
+		MessageAttributes: map[string]snsTypes.MessageAttributeValue{
+			"action": {DataType: aws.String("String"), StringValue: aws.String("changed")},
+		},
+	}
+	mockedSnsApi.On("Publish", context.TODO(), input).Return(&sns.PublishOutput{}, nil).Once()
+
+	err := NewSnsNotifier(mockedSnsApi, "topicArn").Notify(context.TODO(), testChangedEvent)
+
+	assert.Nil(t, err)
+	mockedSnsApi.AssertExpectations(t)
+}
+
+func Test_SnsNotifier_Notify_Error(t *testing.T) {
+	mockedSnsApi := NewMockedSnsApi()
+	mockedSnsApi.On("Publish", context.TODO(), mock.AnythingOfType("*sns.PublishInput")).Return(nil, fmt.Errorf("some error")).Once()
+
+	err := NewSnsNotifier(mockedSnsApi, "topicArn").Notify(context.TODO(), testChangedEvent)
+
+	assert.EqualError(t, err, "error publishing to the sns topic 'topicArn': some error")
+	mockedSnsApi.AssertExpectations(t)
+}
+
+func Test_EventBridgeNotifier_Notify(t *testing.T) {
+	mockedEventBridgeApi := NewMockedEventBridgeApi()
+
+	input := &eventbridge.PutEventsInput{
+		Entries: []eventbridgeTypes.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String("bus"),
+				Source:       aws.String("ecs-sidecar"),
+				DetailType:   aws.String("ECS Sidecar Record Event"),
+				Detail:       aws.String(testRecordEventJson),
+				Resources:    []string{"taskArn"},
+				Time:         aws.Time(testChangedEvent.Time),
+			},
+		},
+	}
+	mockedEventBridgeApi.On("PutEvents", context.TODO(), input).Return(&eventbridge.PutEventsOutput{}, nil).Once()
+
+	err := NewEventBridgeNotifier(mockedEventBridgeApi, "bus").Notify(context.TODO(), testChangedEvent)
+
+	assert.Nil(t, err)
+	mockedEventBridgeApi.AssertExpectations(t)
+}
+
+func Test_EventBridgeNotifier_Notify_FailedEntry(t *testing.T) {
+	mockedEventBridgeApi := NewMockedEventBridgeApi()
+
+	output := &eventbridge.PutEventsOutput{
+		FailedEntryCount: 1,
+		Entries:          []eventbridgeTypes.PutEventsResultEntry{{ErrorCode: aws.String("InternalFailure"), ErrorMessage: aws.String("try again")}},
+	}
+	mockedEventBridgeApi.On("PutEvents", context.TODO(), mock.AnythingOfType("*eventbridge.PutEventsInput")).Return(output, nil).Once()
+
+	err := NewEventBridgeNotifier(mockedEventBridgeApi, "bus").Notify(context.TODO(), testChangedEvent)
+
+	assert.EqualError(t, err, "error putting the event in the bus 'bus': InternalFailure try again")
+	mockedEventBridgeApi.AssertExpectations(t)
+}
+
+func Test_EventBridgeNotifier_Notify_Error(t *testing.T) {
+	mockedEventBridgeApi := NewMockedEventBridgeApi()
+	mockedEventBridgeApi.On("PutEvents", context.TODO(), mock.AnythingOfType("*eventbridge.PutEventsInput")).Return(nil, fmt.Errorf("some error")).Once()
+
+	err := NewEventBridgeNotifier(mockedEventBridgeApi, "bus").Notify(context.TODO(), testChangedEvent)
+
+	assert.EqualError(t, err, "error putting the event in the bus 'bus': some error")
+	mockedEventBridgeApi.AssertExpectations(t)
+}
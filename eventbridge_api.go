@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/stretchr/testify/mock"
+)
+
+type EventBridgeApi interface {
+	PutEvents(ctx context.Context, params *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error)
+}
+
+type AwsEventBridgeApi struct {
+	eventBridgeClient *eventbridge.Client
+}
+
+func NewAwsEventBridgeApi(cfg aws.Config) *AwsEventBridgeApi {
+	eventBridgeClient := eventbridge.NewFromConfig(cfg)
+
+	return &AwsEventBridgeApi{eventBridgeClient: eventBridgeClient}
+}
+
+func (a *AwsEventBridgeApi) PutEvents(ctx context.Context, params *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error) {
+	return a.eventBridgeClient.PutEvents(ctx, params)
+}
+
+type MockedEventBridgeApi struct {
+	mock.Mock
+}
+
+func NewMockedEventBridgeApi() *MockedEventBridgeApi {
+	return &MockedEventBridgeApi{}
+}
+
+func (m *MockedEventBridgeApi) PutEvents(ctx context.Context, params *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*eventbridge.PutEventsOutput), args.Error(1)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	factsEnvFileName  = "network-facts.env"
+	factsJsonFileName = "network-facts.json"
+	factsFileMode     = 0644
+)
+
+// networkFacts are written to a shared volume so the app containers can learn
+// their externally visible address.
+type networkFacts struct {
+	PublicIp         string `json:"publicIp"`
+	PrivateIp        string `json:"privateIp"`
+	Hostname         string `json:"hostname"`
+	TaskId           string `json:"taskId"`
+	AvailabilityZone string `json:"availabilityZone"`
+}
+
+func getNetworkFacts(task ecsTypes.Task, publicIp string, hostname string) networkFacts {
+	return networkFacts{
+		PublicIp:         publicIp,
+		PrivateIp:        getTaskPrivateIp(task),
+		Hostname:         strings.TrimSuffix(hostname, "."),
+		TaskId:           taskIdFromArn(aws.ToString(task.TaskArn)),
+		AvailabilityZone: aws.ToString(task.AvailabilityZone),
+	}
+}
+
+func getTaskPrivateIp(task ecsTypes.Task) string {
+	for _, attachment := range task.Attachments {
+		for _, detail := range attachment.Details {
+			if aws.ToString(detail.Name) == "privateIPv4Address" {
+				return aws.ToString(detail.Value)
+			}
+		}
+	}
+
+	return ""
+}
+
+func taskIdFromArn(taskArn string) string {
+	return taskArn[strings.LastIndex(taskArn, "/")+1:]
+}
+
+func (f networkFacts) env() []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "PUBLIC_IP=%v\n", f.PublicIp)
+	fmt.Fprintf(&buf, "PRIVATE_IP=%v\n", f.PrivateIp)
+	fmt.Fprintf(&buf, "PUBLIC_HOSTNAME=%v\n", f.Hostname)
+	fmt.Fprintf(&buf, "TASK_ID=%v\n", f.TaskId)
+	fmt.Fprintf(&buf, "AVAILABILITY_ZONE=%v\n", f.AvailabilityZone)
+
+	return buf.Bytes()
+}
+
+// writeFacts writes the facts as env and json files in the directory. The
+// files are only rewritten when their content changes.
+func writeFacts(dir string, facts networkFacts) (bool, error) {
+	jsonContent, err := json.MarshalIndent(facts, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("error encoding the network facts: %v", err)
+	}
+
+	envChanged, err := writeFileAtomically(filepath.Join(dir, factsEnvFileName), facts.env())
+	if err != nil {
+		return false, err
+	}
+
+	jsonChanged, err := writeFileAtomically(filepath.Join(dir, factsJsonFileName), append(jsonContent, '\n'))
+	if err != nil {
+		return false, err
+	}
+
+	return envChanged || jsonChanged, nil
+}
+
+// writeFileAtomically writes to a temporary file in the same directory and
+// renames it, so readers never see a partial file.
+func writeFileAtomically(path string, content []byte) (bool, error) {
+	current, err := ioutil.ReadFile(path)
+	if err == nil && bytes.Equal(current, content) {
+		return false, nil
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return false, fmt.Errorf("error creating a temporary file for '%v': %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmp.Name(), factsFileMode)
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("error writing '%v': %v", path, err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return false, fmt.Errorf("error renaming '%v' to '%v': %v", tmp.Name(), path, err)
+	}
+
+	return true, nil
+}
+
+// watchFacts rediscovers the facts periodically, rewriting the files when
+// they change, until the context is done.
+func watchFacts(ctx context.Context, dir string, interval time.Duration, discover func(ctx context.Context) (networkFacts, error)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		facts, err := discover(ctx)
+		if err != nil {
+			log.Printf("Error discovering the network facts: %v\n", err)
+			continue
+		}
+
+		changed, err := writeFacts(dir, facts)
+		if err != nil {
+			log.Printf("Error writing the network facts: %v\n", err)
+		} else if changed {
+			log.Printf("Network facts changed, rewritten in '%v'\n", dir)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var testFacts = networkFacts{
+	PublicIp:         "1.1.1.1",
+	PrivateIp:        "10.0.0.1",
+	Hostname:         "app.example.com",
+	TaskId:           "a1b2c3d4",
+	AvailabilityZone: "eu-west-1a",
+}
+
+func Test_GetNetworkFacts(t *testing.T) {
+	task := ecsTypes.Task{
+		TaskArn:          aws.String("arn:aws:ecs:eu-west-1:111122223333:task/cluster/a1b2c3d4"),
+		AvailabilityZone: aws.String("eu-west-1a"),
+		Attachments: []ecsTypes.Attachment{
+			{
+				Details: []ecsTypes.KeyValuePair{
+					{Name: aws.String("networkInterfaceId"), Value: aws.String("eni-1234")},
+					{Name: aws.String("privateIPv4Address"), Value: aws.String("10.0.0.1")},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, testFacts, getNetworkFacts(task, "1.1.1.1", "app.example.com."))
+}
+
+func Test_WriteFacts(t *testing.T) {
+	dir := t.TempDir()
+
+	changed, err := writeFacts(dir, testFacts)
+
+	assert.True(t, changed)
+	assert.Nil(t, err)
+
+	env, _ := ioutil.ReadFile(filepath.Join(dir, factsEnvFileName))
+	assert.Equal(t, "PUBLIC_IP=1.1.1.1\nPRIVATE_IP=10.0.0.1\nPUBLIC_HOSTNAME=app.example.com\nTASK_ID=a1b2c3d4\nAVAILABILITY_ZONE=eu-west-1a\n", string(env))
+
+	json, _ := ioutil.ReadFile(filepath.Join(dir, factsJsonFileName))
+	assert.JSONEq(t, `{"publicIp":"1.1.1.1","privateIp":"10.0.0.1","hostname":"app.example.com","taskId":"a1b2c3d4","availabilityZone":"eu-west-1a"}`, string(json))
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Equal(t, 2, len(files))
+
+	changed, err = writeFacts(dir, testFacts)
+
+	assert.False(t, changed)
+	assert.Nil(t, err)
+
+	updated := testFacts
+	updated.PublicIp = "2.2.2.2"
+
+	changed, err = writeFacts(dir, updated)
+
+	assert.True(t, changed)
+	assert.Nil(t, err)
+}
+
+func Test_WriteFacts_MissingDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+
+	_, err := writeFacts(dir, testFacts)
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+const (
+	gcCommand = "gc"
+
+	defaultGcInterval    = 5 * time.Minute
+	defaultGcGracePeriod = 10 * time.Minute
+
+	gcActionRemoved       = "removed"
+	gcActionWouldRemove   = "would remove"
+	gcActionGracePeriod   = "kept"
+	gcReasonNoRunningIp   = "no running task has this ip"
+	gcReasonInGracePeriod = "orphan for %v, within the %v grace period"
+)
+
+type gcReportEntry struct {
+	Record string
+	Ip     string
+	Action string
+	Reason string
+}
+
+// GarbageCollector removes from the records owned by the sidecar the ips that
+// no longer belong to a running task, once they have been orphan for longer
+// than the grace period. Since when each ip is orphan is kept in the owner
+// record, so the grace period survives restarts and --once runs. Dry runs
+// don't change the owner records, so they keep the marks in memory instead.
+type GarbageCollector struct {
+	ecsApi       EcsApi
+	ec2Api       Ec2Api
+	route53Api   Route53Api
+	clusterName  string
+	hostedZoneId string
+	nameSuffix   string
+	gracePeriod  time.Duration
+	dryRun       bool
+	dryRunMarks  map[string]map[string]time.Time
+	now          func() time.Time
+}
+
+func NewGarbageCollector(ecsApi EcsApi, ec2Api Ec2Api, route53Api Route53Api, clusterName string, hostedZoneId string, nameSuffix string, gracePeriod time.Duration, dryRun bool) *GarbageCollector {
+	return &GarbageCollector{
+		ecsApi:       ecsApi,
+		ec2Api:       ec2Api,
+		route53Api:   route53Api,
+		clusterName:  clusterName,
+		hostedZoneId: hostedZoneId,
+		nameSuffix:   nameSuffix,
+		gracePeriod:  gracePeriod,
+		dryRun:       dryRun,
+		dryRunMarks:  map[string]map[string]time.Time{},
+		now:          time.Now,
+	}
+}
+
+func runGarbageCollector(ctx context.Context, cfg aws.Config, clusterName string, defaults dnsSettings, args []string) {
+	flags := flag.NewFlagSet(gcCommand, flag.ExitOnError)
+	nameSuffix := flags.String("name-suffix", os.Getenv("GC_NAME_SUFFIX"), "only the owned records whose name ends with this suffix are collected")
+	gracePeriod := flags.Duration("grace-period", defaultGcGracePeriod, "how long an ip must be orphan before it is removed")
+	interval := flags.Duration("interval", defaultGcInterval, "time between collections")
+	once := flags.Bool("once", false, "run a single collection and exit")
+	dryRun := flags.Bool("dry-run", false, "report the orphan ips without removing them")
+	allowlistSpec := flags.String("allowlist", os.Getenv("ALLOWLIST"), "managed prefix list or security group whose entries of stopped tasks are removed too")
+	flags.Parse(args)
+
+	if len(*nameSuffix) == 0 && len(*allowlistSpec) == 0 {
+		log.Fatal("no name suffix or allowlist configured")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ecsApi := InitEcsApi(cfg)
+	ec2Api := InitEc2Api(cfg)
+
+	var gc *GarbageCollector
+	if len(*nameSuffix) > 0 {
+		route53Api := NewRetryingRoute53Api(InitRoute53Api(cfg), defaultRoute53RetryPolicy(), newTokenBucket(route53RequestsPerSecond, route53RequestsBurst))
+
+		hostedZoneId, err := getHostedZoneId(ctx, route53Api, defaults)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		gc = NewGarbageCollector(ecsApi, ec2Api, route53Api, clusterName, hostedZoneId, *nameSuffix, *gracePeriod, *dryRun)
+	}
+
+	var allowlist Allowlist
+	if len(*allowlistSpec) > 0 {
+		var err error
+
+		allowlist, err = newAllowlist(ec2Api, *allowlistSpec)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	for {
+		report := []gcReportEntry{}
+
+		if gc != nil {
+			recordsReport, err := gc.Collect(ctx)
+			if err != nil {
+				log.Printf("Error collecting stale records: %v\n", err)
+			}
+
+			report = append(report, recordsReport...)
+		}
+
+		if allowlist != nil {
+			allowlistReport, err := collectAllowlist(ctx, ecsApi, clusterName, allowlist, *dryRun)
+			if err != nil {
+				log.Printf("Error collecting stale allowlist entries: %v\n", err)
+			}
+
+			report = append(report, allowlistReport...)
+		}
+
+		printGcReport(os.Stdout, report)
+
+		if *once {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*interval):
+		}
+	}
+}
+
+func (g *GarbageCollector) Collect(ctx context.Context) ([]gcReportEntry, error) {
+	records, err := g.getOwnedRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	withEgress := false
+	for _, record := range records {
+		withEgress = withEgress || record.Owner.Kind() == ownerKindEgress
+	}
+
+	runningIps, err := g.getRunningIps(ctx, withEgress)
+	if err != nil {
+		return nil, err
+	}
+
+	now := g.now()
+	report := []gcReportEntry{}
+
+	for _, record := range records {
+		name := aws.ToString(record.RecordSet.Name)
+		orphanSince := map[string]time.Time{}
+		removableIps := map[string]bool{}
+		dryRunMarks := map[string]time.Time{}
+
+		for _, ip := range recordSetIps(&record.RecordSet) {
+			if runningIps[ip] {
+				continue
+			}
+
+			since, ok := record.Owner.OrphanSince[ip]
+			if !ok && g.dryRun {
+				since, ok = g.dryRunMarks[name][ip]
+			}
+			if !ok {
+				since = now
+			}
+			dryRunMarks[ip] = since
+
+			orphanFor := now.Sub(since)
+			if orphanFor < g.gracePeriod {
+				orphanSince[ip] = since
+				report = append(report, gcReportEntry{Record: name, Ip: ip, Action: gcActionGracePeriod, Reason: fmt.Sprintf(gcReasonInGracePeriod, orphanFor, g.gracePeriod)})
+				continue
+			}
+
+			removableIps[ip] = true
+		}
+
+		action := gcActionWouldRemove
+		if g.dryRun {
+			g.dryRunMarks[name] = dryRunMarks
+		} else {
+			err = g.updateRecord(ctx, record, removableIps, orphanSince)
+			if err != nil {
+				return report, err
+			}
+
+			action = gcActionRemoved
+		}
+
+		for _, ip := range recordSetIps(&record.RecordSet) {
+			if removableIps[ip] {
+				report = append(report, gcReportEntry{Record: name, Ip: ip, Action: action, Reason: gcReasonNoRunningIp})
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// updateRecord removes the ips from the record and persists the orphan marks
+// in its owner record, in one batch. The A record is replaced with DELETE and
+// CREATE, so the batch fails if a task changed the record in the meantime.
+func (g *GarbageCollector) updateRecord(ctx context.Context, record ownedRecord, removableIps map[string]bool, orphanSince map[string]time.Time) error {
+	remainingIps := []string{}
+	for _, ip := range recordSetIps(&record.RecordSet) {
+		if !removableIps[ip] {
+			remainingIps = append(remainingIps, ip)
+		}
+	}
+
+	if len(remainingIps) == 0 {
+		return deleteOwnedRecord(ctx, g.route53Api, g.hostedZoneId, record)
+	}
+
+	name := aws.ToString(record.RecordSet.Name)
+	changes := []route53Types.Change{}
+
+	if len(removableIps) > 0 {
+		current := record.RecordSet
+
+		updated := record.RecordSet
+		updated.ResourceRecords = []route53Types.ResourceRecord{}
+		for _, ip := range remainingIps {
+			updated.ResourceRecords = append(updated.ResourceRecords, route53Types.ResourceRecord{Value: aws.String(ip)})
+		}
+
+		changes = append(changes,
+			route53Types.Change{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &current},
+			route53Types.Change{Action: route53Types.ChangeActionCreate, ResourceRecordSet: &updated},
+		)
+	}
+
+	if !sameOrphanMarks(orphanSince, record.Owner.OrphanSince) {
+		owner := recordOwner{Id: record.Owner.Id, OrphanSince: orphanSince}
+		changes = append(changes, route53Types.Change{Action: route53Types.ChangeActionUpsert, ResourceRecordSet: ownerRecordSet(name, owner)})
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	changeResourceRecordSetsInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch:  &route53Types.ChangeBatch{Changes: changes},
+		HostedZoneId: aws.String(g.hostedZoneId),
+	}
+
+	_, err := g.route53Api.ChangeResourceRecordSets(ctx, changeResourceRecordSetsInput)
+	if err != nil {
+		return fmt.Errorf("error updating the record set '%v' in Route53 hosted zone '%v': %v", name, g.hostedZoneId, err)
+	}
+
+	return nil
+}
+
+// getOwnedRecords returns the records under the name suffix whose owner
+// record says they are kept by the tasks of this cluster, including the
+// egress records. Records without an owner record are never touched.
+func (g *GarbageCollector) getOwnedRecords(ctx context.Context) ([]ownedRecord, error) {
+	records, err := listOwnedRecords(ctx, g.route53Api, g.hostedZoneId)
+	if err != nil {
+		return nil, err
+	}
+
+	ownedRecords := []ownedRecord{}
+	for _, record := range records {
+		if !g.isOwned(aws.ToString(record.RecordSet.Name)) || record.Owner.Cluster() != clusterKey(g.clusterName) {
+			continue
+		}
+
+		switch record.Owner.Kind() {
+		case ownerKindTask, ownerKindController, ownerKindEgress:
+			ownedRecords = append(ownedRecords, record)
+		}
+	}
+
+	return ownedRecords, nil
+}
+
+func (g *GarbageCollector) isOwned(name string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimSuffix(name, ".")), strings.ToLower(strings.TrimSuffix(g.nameSuffix, ".")))
+}
+
+// getRunningIps returns the public ips of the running tasks and, withEgress,
+// the ips of the NAT gateways they go out through.
+func (g *GarbageCollector) getRunningIps(ctx context.Context, withEgress bool) (map[string]bool, error) {
+	tasks, err := getServiceTasks(ctx, g.ecsApi, g.clusterName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	enis := []string{}
+	for _, task := range tasks {
+		eni, err := getTaskEni(task)
+		if err == nil {
+			enis = append(enis, eni)
+		}
+	}
+
+	publicIps, err := getPublicIpsFromTaskEnis(ctx, g.ec2Api, enis)
+	if err != nil {
+		return nil, err
+	}
+
+	runningIps := map[string]bool{}
+	for _, publicIp := range publicIps {
+		runningIps[publicIp] = true
+	}
+
+	if withEgress {
+		egressIps, err := getEgressIpsFromTaskEnis(ctx, g.ec2Api, enis)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, egressIp := range egressIps {
+			runningIps[egressIp] = true
+		}
+	}
+
+	return runningIps, nil
+}
+
+func printGcReport(w io.Writer, report []gcReportEntry) {
+	if len(report) == 0 {
+		fmt.Fprintln(w, "No stale records found")
+		return
+	}
+
+	for _, entry := range report {
+		fmt.Fprintf(w, "%v %v from %v: %v\n", entry.Action, entry.Ip, entry.Record, entry.Reason)
+	}
+}
+
+func sameOrphanMarks(a map[string]time.Time, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for ip, since := range a {
+		if other, ok := b[ip]; !ok || !other.Equal(since) {
+			return false
+		}
+	}
+
+	return true
+}
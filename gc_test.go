@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	sharedRecordSet = route53Types.ResourceRecordSet{
+		Name:            aws.String("a.tasks.example.com."),
+		Type:            route53Types.RRTypeA,
+		TTL:             aws.Int64(60),
+		ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("1.1.1.1")}, {Value: aws.String("2.2.2.2")}},
+	}
+	orphanRecordSet = route53Types.ResourceRecordSet{
+		Name:            aws.String("b.tasks.example.com."),
+		Type:            route53Types.RRTypeA,
+		TTL:             aws.Int64(60),
+		ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("3.3.3.3")}},
+	}
+	staticRecordSet = route53Types.ResourceRecordSet{
+		Name:            aws.String("static.tasks.example.com."),
+		Type:            route53Types.RRTypeA,
+		TTL:             aws.Int64(60),
+		ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("5.5.5.5")}},
+	}
+	otherClusterRecordSet = route53Types.ResourceRecordSet{
+		Name:            aws.String("c.tasks.example.com."),
+		Type:            route53Types.RRTypeA,
+		TTL:             aws.Int64(60),
+		ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("6.6.6.6")}},
+	}
+	notOwnedRecordSet = route53Types.ResourceRecordSet{
+		Name:            aws.String("www.example.com."),
+		Type:            route53Types.RRTypeA,
+		TTL:             aws.Int64(60),
+		ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("4.4.4.4")}},
+	}
+)
+
+// setupGcMocks lists the records with their owner records, which carry the
+// given orphan marks.
+func setupGcMocks(ctx context.Context, mockedEcsApi *MockedEcsApi, mockedEc2Api *MockedEc2Api, mockedRoute53Api *MockedRoute53Api, orphanSince map[string]time.Time) (route53Types.ResourceRecordSet, route53Types.ResourceRecordSet) {
+	sharedOwnerSet := *ownerRecordSet("a.tasks.example.com.", recordOwner{Id: "task/cluster", OrphanSince: filterOrphanMarks(orphanSince, "1.1.1.1")})
+	orphanOwnerSet := *ownerRecordSet("b.tasks.example.com.", recordOwner{Id: "controller/cluster", OrphanSince: filterOrphanMarks(orphanSince, "3.3.3.3")})
+	otherClusterOwnerSet := *ownerRecordSet("c.tasks.example.com.", recordOwner{Id: "task/other"})
+
+	firstPageInput := &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String("zoneId")}
+	firstPageOutput := &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets: []route53Types.ResourceRecordSet{sharedOwnerSet, orphanOwnerSet, otherClusterOwnerSet, sharedRecordSet},
+		IsTruncated:        true,
+		NextRecordName:     aws.String("b.tasks.example.com."),
+		NextRecordType:     route53Types.RRTypeA,
+	}
+	secondPageInput := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String("zoneId"),
+		StartRecordName: aws.String("b.tasks.example.com."),
+		StartRecordType: route53Types.RRTypeA,
+	}
+	secondPageOutput := &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets: []route53Types.ResourceRecordSet{orphanRecordSet, otherClusterRecordSet, staticRecordSet, notOwnedRecordSet},
+	}
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, firstPageInput).Return(firstPageOutput, nil)
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, secondPageInput).Return(secondPageOutput, nil)
+
+	listTasksInput := &ecs.ListTasksInput{
+		Cluster:       aws.String("cluster"),
+		DesiredStatus: ecsTypes.DesiredStatusRunning,
+	}
+
+	mockedEcsApi.On("ListTasks", ctx, listTasksInput).Return(&ecs.ListTasksOutput{TaskArns: []string{"task1"}}, nil)
+
+	describeTasksInput := &ecs.DescribeTasksInput{
+		Cluster: aws.String("cluster"),
+		Tasks:   []string{"task1"},
+		Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags},
+	}
+
+	mockedEcsApi.On("DescribeTasks", ctx, describeTasksInput).Return(&ecs.DescribeTasksOutput{Tasks: []ecsTypes.Task{taskWithEni("task1", "eni1")}}, nil)
+
+	describeNetworkInterfacesOutput := &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []ec2Types.NetworkInterface{networkInterfaceWithIp("eni1", "2.2.2.2")},
+	}
+
+	mockedEc2Api.On("DescribeNetworkInterfaces", ctx, taskEnisInput("eni1")).Return(describeNetworkInterfacesOutput, nil)
+
+	return sharedOwnerSet, orphanOwnerSet
+}
+
+func filterOrphanMarks(orphanSince map[string]time.Time, ip string) map[string]time.Time {
+	filtered := map[string]time.Time{}
+	if since, ok := orphanSince[ip]; ok {
+		filtered[ip] = since
+	}
+
+	return filtered
+}
+
+func Test_GarbageCollector_Collect_MarksNewOrphans(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedEc2Api := NewMockedEc2Api()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	setupGcMocks(ctx, mockedEcsApi, mockedEc2Api, mockedRoute53Api, nil)
+
+	now := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	gc := NewGarbageCollector(mockedEcsApi, mockedEc2Api, mockedRoute53Api, "cluster", "zoneId", "tasks.example.com", 10*time.Minute, false)
+	gc.now = func() time.Time {
+		return now
+	}
+
+	changeOutput := &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53Types.ChangeInfo{Status: route53Types.ChangeStatusPending},
+	}
+
+	for _, record := range []struct{ name, ip, owner string }{{"a.tasks.example.com.", "1.1.1.1", "task/cluster"}, {"b.tasks.example.com.", "3.3.3.3", "controller/cluster"}} {
+		markInput := &route53.ChangeResourceRecordSetsInput{
+			ChangeBatch: &route53Types.ChangeBatch{
+				Changes: []route53Types.Change{ownerChange(record.name, recordOwner{Id: record.owner, OrphanSince: map[string]time.Time{record.ip: now}})},
+			},
+			HostedZoneId: aws.String("zoneId"),
+		}
+
+		mockedRoute53Api.On("ChangeResourceRecordSets", ctx, markInput).Return(changeOutput, nil).Once()
+	}
+
+	report, err := gc.Collect(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []gcReportEntry{
+		{Record: "a.tasks.example.com.", Ip: "1.1.1.1", Action: gcActionGracePeriod, Reason: "orphan for 0s, within the 10m0s grace period"},
+		{Record: "b.tasks.example.com.", Ip: "3.3.3.3", Action: gcActionGracePeriod, Reason: "orphan for 0s, within the 10m0s grace period"},
+	}, report)
+
+	mockedEcsApi.AssertExpectations(t)
+	mockedEc2Api.AssertExpectations(t)
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_GarbageCollector_Collect_RemovesOrphansAfterGracePeriod(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedEc2Api := NewMockedEc2Api()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	now := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	firstSeen := now.Add(-11 * time.Minute)
+
+	// a new collector, like after a restart, reads the marks from the owner records
+	_, orphanOwnerSet := setupGcMocks(ctx, mockedEcsApi, mockedEc2Api, mockedRoute53Api, map[string]time.Time{"1.1.1.1": firstSeen, "3.3.3.3": firstSeen})
+
+	gc := NewGarbageCollector(mockedEcsApi, mockedEc2Api, mockedRoute53Api, "cluster", "zoneId", "tasks.example.com", 10*time.Minute, false)
+	gc.now = func() time.Time {
+		return now
+	}
+
+	updatedRecordSet := sharedRecordSet
+	updatedRecordSet.ResourceRecords = []route53Types.ResourceRecord{{Value: aws.String("2.2.2.2")}}
+
+	updateInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &sharedRecordSet},
+				{Action: route53Types.ChangeActionCreate, ResourceRecordSet: &updatedRecordSet},
+				ownerChange("a.tasks.example.com.", recordOwner{Id: "task/cluster", OrphanSince: map[string]time.Time{}}),
+			},
+		},
+		HostedZoneId: aws.String("zoneId"),
+	}
+	changeOutput := &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53Types.ChangeInfo{Status: route53Types.ChangeStatusPending},
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, updateInput).Return(changeOutput, nil).Once()
+
+	deleteInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &orphanRecordSet},
+				{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &orphanOwnerSet},
+			},
+		},
+		HostedZoneId: aws.String("zoneId"),
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, deleteInput).Return(changeOutput, nil).Once()
+
+	report, err := gc.Collect(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []gcReportEntry{
+		{Record: "a.tasks.example.com.", Ip: "1.1.1.1", Action: gcActionRemoved, Reason: gcReasonNoRunningIp},
+		{Record: "b.tasks.example.com.", Ip: "3.3.3.3", Action: gcActionRemoved, Reason: gcReasonNoRunningIp},
+	}, report)
+
+	mockedEcsApi.AssertExpectations(t)
+	mockedEc2Api.AssertExpectations(t)
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_GarbageCollector_Collect_EgressRecord(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedEc2Api := NewMockedEc2Api()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	now := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	egressRecordSet := route53Types.ResourceRecordSet{
+		Name:            aws.String("egress.tasks.example.com."),
+		Type:            route53Types.RRTypeA,
+		TTL:             aws.Int64(60),
+		ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("3.3.3.3")}, {Value: aws.String("4.4.4.4")}},
+	}
+	egressOwnerSet := *ownerRecordSet("egress.tasks.example.com.", recordOwner{Id: "egress/cluster", OrphanSince: map[string]time.Time{"4.4.4.4": now.Add(-11 * time.Minute)}})
+
+	listOutput := &route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{egressOwnerSet, egressRecordSet}}
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String("zoneId")}).Return(listOutput, nil).Once()
+
+	mockedEcsApi.On("ListTasks", ctx, &ecs.ListTasksInput{Cluster: aws.String("cluster"), DesiredStatus: ecsTypes.DesiredStatusRunning}).Return(&ecs.ListTasksOutput{TaskArns: []string{"task1"}}, nil).Once()
+	mockedEcsApi.On("DescribeTasks", ctx, &ecs.DescribeTasksInput{Cluster: aws.String("cluster"), Tasks: []string{"task1"}, Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags}}).Return(&ecs.DescribeTasksOutput{Tasks: []ecsTypes.Task{taskWithEni("task1", "eni1")}}, nil).Once()
+
+	privateEni := ec2Types.NetworkInterface{NetworkInterfaceId: aws.String("eni1"), SubnetId: aws.String("subnet-1"), VpcId: aws.String("vpc-1")}
+	mockedEc2Api.On("DescribeNetworkInterfaces", ctx, taskEnisInput("eni1")).Return(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []ec2Types.NetworkInterface{privateEni}}, nil).Twice()
+	mockedEc2Api.On("DescribeRouteTables", ctx, subnetRouteTablesInput()).Return(&ec2.DescribeRouteTablesOutput{RouteTables: []ec2Types.RouteTable{{Routes: natRoutes("nat-1")}}}, nil).Once()
+	describeNatGateway(mockedEc2Api, "nat-1", "3.3.3.3")
+
+	updatedRecordSet := egressRecordSet
+	updatedRecordSet.ResourceRecords = []route53Types.ResourceRecord{{Value: aws.String("3.3.3.3")}}
+
+	updateInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &egressRecordSet},
+				{Action: route53Types.ChangeActionCreate, ResourceRecordSet: &updatedRecordSet},
+				ownerChange("egress.tasks.example.com.", recordOwner{Id: "egress/cluster", OrphanSince: map[string]time.Time{}}),
+			},
+		},
+		HostedZoneId: aws.String("zoneId"),
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, updateInput).Return(&route53.ChangeResourceRecordSetsOutput{}, nil).Once()
+
+	gc := NewGarbageCollector(mockedEcsApi, mockedEc2Api, mockedRoute53Api, "cluster", "zoneId", "tasks.example.com", 10*time.Minute, false)
+	gc.now = func() time.Time {
+		return now
+	}
+
+	report, err := gc.Collect(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []gcReportEntry{
+		{Record: "egress.tasks.example.com.", Ip: "4.4.4.4", Action: gcActionRemoved, Reason: gcReasonNoRunningIp},
+	}, report)
+
+	mockedEcsApi.AssertExpectations(t)
+	mockedEc2Api.AssertExpectations(t)
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_GarbageCollector_Collect_DryRun(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedEc2Api := NewMockedEc2Api()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	setupGcMocks(ctx, mockedEcsApi, mockedEc2Api, mockedRoute53Api, nil)
+
+	gc := NewGarbageCollector(mockedEcsApi, mockedEc2Api, mockedRoute53Api, "cluster", "zoneId", "tasks.example.com.", 0, true)
+
+	report, err := gc.Collect(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []gcReportEntry{
+		{Record: "a.tasks.example.com.", Ip: "1.1.1.1", Action: gcActionWouldRemove, Reason: gcReasonNoRunningIp},
+		{Record: "b.tasks.example.com.", Ip: "3.3.3.3", Action: gcActionWouldRemove, Reason: gcReasonNoRunningIp},
+	}, report)
+
+	mockedEcsApi.AssertExpectations(t)
+	mockedEc2Api.AssertExpectations(t)
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_GarbageCollector_Collect_DryRun_KeepsOrphanMarks(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedEc2Api := NewMockedEc2Api()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	setupGcMocks(ctx, mockedEcsApi, mockedEc2Api, mockedRoute53Api, nil)
+
+	now := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	gc := NewGarbageCollector(mockedEcsApi, mockedEc2Api, mockedRoute53Api, "cluster", "zoneId", "tasks.example.com.", 10*time.Minute, true)
+	gc.now = func() time.Time {
+		return now
+	}
+
+	report, err := gc.Collect(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []gcReportEntry{
+		{Record: "a.tasks.example.com.", Ip: "1.1.1.1", Action: gcActionGracePeriod, Reason: "orphan for 0s, within the 10m0s grace period"},
+		{Record: "b.tasks.example.com.", Ip: "3.3.3.3", Action: gcActionGracePeriod, Reason: "orphan for 0s, within the 10m0s grace period"},
+	}, report)
+
+	now = now.Add(5 * time.Minute)
+
+	report, err = gc.Collect(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []gcReportEntry{
+		{Record: "a.tasks.example.com.", Ip: "1.1.1.1", Action: gcActionGracePeriod, Reason: "orphan for 5m0s, within the 10m0s grace period"},
+		{Record: "b.tasks.example.com.", Ip: "3.3.3.3", Action: gcActionGracePeriod, Reason: "orphan for 5m0s, within the 10m0s grace period"},
+	}, report)
+
+	now = now.Add(10 * time.Minute)
+
+	report, err = gc.Collect(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []gcReportEntry{
+		{Record: "a.tasks.example.com.", Ip: "1.1.1.1", Action: gcActionWouldRemove, Reason: gcReasonNoRunningIp},
+		{Record: "b.tasks.example.com.", Ip: "3.3.3.3", Action: gcActionWouldRemove, Reason: gcReasonNoRunningIp},
+	}, report)
+
+	mockedEcsApi.AssertExpectations(t)
+	mockedEc2Api.AssertExpectations(t)
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_PrintGcReport(t *testing.T) {
+	var buf bytes.Buffer
+
+	printGcReport(&buf, []gcReportEntry{{Record: "a.tasks.example.com.", Ip: "1.1.1.1", Action: gcActionRemoved, Reason: gcReasonNoRunningIp}})
+
+	assert.Equal(t, "removed 1.1.1.1 from a.tasks.example.com.: no running task has this ip\n", buf.String())
+}
+
+func Test_PrintGcReport_Empty(t *testing.T) {
+	var buf bytes.Buffer
+
+	printGcReport(&buf, []gcReportEntry{})
+
+	assert.Equal(t, "No stale records found\n", buf.String())
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	containerHealthHealthy = "HEALTHY"
+
+	defaultHealthInterval     = 5 * time.Second
+	defaultHealthyThreshold   = 1
+	defaultUnhealthyThreshold = 3
+)
+
+type containerMetadata struct {
+	Name   string
+	Health containerHealth
+}
+
+type containerHealth struct {
+	Status string `json:"status"`
+}
+
+type healthCheck func(ctx context.Context) (bool, error)
+
+// HealthWatcher runs a health check, flipping its state only after the same
+// result has been seen the configured number of consecutive times, so a
+// single failed check doesn't withdraw the record.
+type HealthWatcher struct {
+	check              healthCheck
+	healthyThreshold   int
+	unhealthyThreshold int
+	healthy            bool
+	streak             int
+}
+
+func NewHealthWatcher(check healthCheck, healthyThreshold int, unhealthyThreshold int) *HealthWatcher {
+	return &HealthWatcher{
+		check:              check,
+		healthyThreshold:   healthyThreshold,
+		unhealthyThreshold: unhealthyThreshold,
+	}
+}
+
+// Check returns the current state and whether this check changed it.
+func (w *HealthWatcher) Check(ctx context.Context) (bool, bool, error) {
+	healthy, err := w.check(ctx)
+	if err != nil {
+		return w.healthy, false, err
+	}
+
+	return w.observe(healthy)
+}
+
+func (w *HealthWatcher) observe(healthy bool) (bool, bool, error) {
+	if healthy == w.healthy {
+		w.streak = 0
+
+		return w.healthy, false, nil
+	}
+
+	w.streak++
+
+	threshold := w.unhealthyThreshold
+	if healthy {
+		threshold = w.healthyThreshold
+	}
+
+	if w.streak < threshold {
+		return w.healthy, false, nil
+	}
+
+	w.healthy = healthy
+	w.streak = 0
+
+	return w.healthy, true, nil
+}
+
+// containersHealthCheck is healthy when all the containers report HEALTHY in
+// the task metadata.
+func containersHealthCheck(client MetadataEndpointClient, containers []string) healthCheck {
+	return func(ctx context.Context) (bool, error) {
+		health, err := getContainersHealth(client)
+		if err != nil {
+			return false, err
+		}
+
+		return allContainersHealthy(health, containers), nil
+	}
+}
+
+// allHealthChecks is healthy when every check is healthy.
+func allHealthChecks(checks ...healthCheck) healthCheck {
+	return func(ctx context.Context) (bool, error) {
+		healthy := true
+
+		for _, check := range checks {
+			ok, err := check(ctx)
+			if err != nil {
+				return false, err
+			}
+
+			healthy = healthy && ok
+		}
+
+		return healthy, nil
+	}
+}
+
+func allContainersHealthy(health map[string]string, containers []string) bool {
+	for _, container := range containers {
+		status, ok := health[container]
+		if !ok {
+			log.Printf("Container '%v' not found in the task metadata\n", container)
+
+			return false
+		}
+
+		if status != containerHealthHealthy {
+			log.Printf("Container '%v' is %v\n", container, status)
+
+			return false
+		}
+	}
+
+	return true
+}
+
+func getContainersHealth(client MetadataEndpointClient) (map[string]string, error) {
+	url := os.Getenv("ECS_CONTAINER_METADATA_URI_V4") + "/task"
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting metadata: %v", err)
+	}
+	defer resp.Body.Close()
+
+	metadata := taskMetadata{}
+
+	err = json.NewDecoder(resp.Body).Decode(&metadata)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the metadata request response: %v", err)
+	}
+
+	health := map[string]string{}
+	for _, container := range metadata.Containers {
+		health[container.Name] = container.Health.Status
+	}
+
+	return health, nil
+}
+
+func parseList(value string) []string {
+	items := []string{}
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// runHealthGated publishes the record once the task is healthy. In daemon mode
+// it keeps watching it, withdrawing the record when it turns unhealthy and
+// publishing it again when it recovers.
+func runHealthGated(ctx context.Context, watcher *HealthWatcher, interval time.Duration, daemon bool, publish func(ctx context.Context) error, withdraw func(ctx context.Context) error) error {
+	for {
+		healthy, changed, err := watcher.Check(ctx)
+		if err != nil {
+			log.Printf("Error checking the task health: %v\n", err)
+		}
+
+		if changed && healthy {
+			log.Println("The task is healthy, publishing")
+
+			err = publish(ctx)
+			if err != nil {
+				if !daemon {
+					return err
+				}
+
+				log.Printf("Error publishing: %v\n", err)
+
+				// retried on the next check
+				watcher.healthy = false
+			}
+		} else if changed {
+			log.Println("The task is unhealthy, withdrawing")
+
+			err = withdraw(ctx)
+			if err != nil {
+				log.Printf("Error withdrawing: %v\n", err)
+			}
+		}
+
+		if healthy && !daemon {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
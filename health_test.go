@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func taskMetadataResponse(statuses map[string]string) *http.Response {
+	metadata := taskMetadata{TaskARN: "taskArn"}
+	for name, status := range statuses {
+		metadata.Containers = append(metadata.Containers, containerMetadata{Name: name, Health: containerHealth{Status: status}})
+	}
+
+	responseJson, _ := json.Marshal(&metadata)
+
+	return &http.Response{
+		Body: ioutil.NopCloser(bytes.NewReader(responseJson)),
+	}
+}
+
+func Test_GetContainersHealth_RequestError(t *testing.T) {
+	mockedMetadataEndpointClient := NewMockedMetadataEndpointClient()
+	os.Setenv("ECS_CONTAINER_METADATA_URI_V4", "http://endpointUrl")
+
+	mockedMetadataEndpointClient.On("Get", "http://endpointUrl/task").Return(nil, fmt.Errorf("some error"))
+
+	result, err := getContainersHealth(mockedMetadataEndpointClient)
+
+	assert.Nil(t, result)
+	assert.EqualError(t, err, "error requesting metadata: some error")
+
+	mockedMetadataEndpointClient.AssertExpectations(t)
+}
+
+func Test_GetContainersHealth_Ok(t *testing.T) {
+	mockedMetadataEndpointClient := NewMockedMetadataEndpointClient()
+	os.Setenv("ECS_CONTAINER_METADATA_URI_V4", "http://endpointUrl")
+
+	mockedMetadataEndpointClient.On("Get", "http://endpointUrl/task").Return(taskMetadataResponse(map[string]string{"app": "HEALTHY", "sidecar": "UNKNOWN"}), nil)
+
+	result, err := getContainersHealth(mockedMetadataEndpointClient)
+
+	assert.Equal(t, map[string]string{"app": "HEALTHY", "sidecar": "UNKNOWN"}, result)
+	assert.Nil(t, err)
+
+	mockedMetadataEndpointClient.AssertExpectations(t)
+}
+
+func Test_AllContainersHealthy(t *testing.T) {
+	containers := []string{"app", "worker"}
+
+	assert.True(t, allContainersHealthy(map[string]string{"app": "HEALTHY", "worker": "HEALTHY", "other": "UNHEALTHY"}, containers))
+	assert.False(t, allContainersHealthy(map[string]string{"app": "HEALTHY", "worker": "UNKNOWN"}, containers))
+	assert.False(t, allContainersHealthy(map[string]string{"app": "HEALTHY"}, containers))
+}
+
+func Test_AllHealthChecks(t *testing.T) {
+	healthy := func(ctx context.Context) (bool, error) { return true, nil }
+	unhealthy := func(ctx context.Context) (bool, error) { return false, nil }
+	failing := func(ctx context.Context) (bool, error) { return false, fmt.Errorf("some error") }
+
+	result, err := allHealthChecks(healthy, healthy)(context.TODO())
+	assert.True(t, result)
+	assert.Nil(t, err)
+
+	result, err = allHealthChecks(healthy, unhealthy)(context.TODO())
+	assert.False(t, result)
+	assert.Nil(t, err)
+
+	_, err = allHealthChecks(healthy, failing)(context.TODO())
+	assert.EqualError(t, err, "some error")
+}
+
+func Test_HealthWatcher_Observe_Hysteresis(t *testing.T) {
+	watcher := NewHealthWatcher(nil, 2, 3)
+
+	observations := []bool{true, true, false, false, true, false, false, false}
+	expected := []struct {
+		healthy bool
+		changed bool
+	}{
+		{false, false},
+		{true, true},
+		{true, false},
+		{true, false},
+		{true, false},
+		{true, false},
+		{true, false},
+		{false, true},
+	}
+
+	for i, observation := range observations {
+		healthy, changed, err := watcher.observe(observation)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected[i].healthy, healthy, "observation %v", i)
+		assert.Equal(t, expected[i].changed, changed, "observation %v", i)
+	}
+}
+
+func Test_RunHealthGated_PublishesOnceHealthy(t *testing.T) {
+	mockedMetadataEndpointClient := NewMockedMetadataEndpointClient()
+	os.Setenv("ECS_CONTAINER_METADATA_URI_V4", "http://endpointUrl")
+
+	mockedMetadataEndpointClient.On("Get", "http://endpointUrl/task").Return(taskMetadataResponse(map[string]string{"app": "UNKNOWN"}), nil).Once()
+	mockedMetadataEndpointClient.On("Get", "http://endpointUrl/task").Return(taskMetadataResponse(map[string]string{"app": "HEALTHY"}), nil).Once()
+
+	watcher := NewHealthWatcher(containersHealthCheck(mockedMetadataEndpointClient, []string{"app"}), 1, 1)
+
+	published := 0
+	err := runHealthGated(context.TODO(), watcher, 0, false, func(ctx context.Context) error {
+		published++
+		return nil
+	}, func(ctx context.Context) error {
+		t.Fatal("unexpected withdraw")
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, published)
+
+	mockedMetadataEndpointClient.AssertExpectations(t)
+}
+
+func Test_RunHealthGated_Daemon_WithdrawsWhenUnhealthy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	mockedMetadataEndpointClient := NewMockedMetadataEndpointClient()
+	os.Setenv("ECS_CONTAINER_METADATA_URI_V4", "http://endpointUrl")
+
+	mockedMetadataEndpointClient.On("Get", "http://endpointUrl/task").Return(taskMetadataResponse(map[string]string{"app": "HEALTHY"}), nil).Once()
+	mockedMetadataEndpointClient.On("Get", "http://endpointUrl/task").Return(taskMetadataResponse(map[string]string{"app": "UNHEALTHY"}), nil).Once()
+	mockedMetadataEndpointClient.On("Get", "http://endpointUrl/task").Return(taskMetadataResponse(map[string]string{"app": "UNHEALTHY"}), nil)
+
+	watcher := NewHealthWatcher(containersHealthCheck(mockedMetadataEndpointClient, []string{"app"}), 1, 2)
+
+	calls := []string{}
+	err := runHealthGated(ctx, watcher, 0, true, func(ctx context.Context) error {
+		calls = append(calls, "publish")
+		return nil
+	}, func(ctx context.Context) error {
+		calls = append(calls, "withdraw")
+		cancel()
+		return nil
+	})
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []string{"publish", "withdraw"}, calls)
+
+	mockedMetadataEndpointClient.AssertExpectations(t)
+}
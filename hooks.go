@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const defaultHookTimeout = 30 * time.Second
+
+// hookInput is written as json to the stdin of the hook commands.
+type hookInput struct {
+	Event  string `json:"event"`
+	Record string `json:"record"`
+	OldIp  string `json:"oldIp,omitempty"`
+	networkFacts
+}
+
+// Hook runs a shell command when the record changes, passing the network
+// facts in its environment and stdin.
+type Hook struct {
+	name    string
+	command string
+	timeout time.Duration
+}
+
+func NewHook(name string, command string, timeout time.Duration) *Hook {
+	return &Hook{name: name, command: command, timeout: timeout}
+}
+
+func (h *Hook) Run(ctx context.Context, event lifecycleEvent, facts networkFacts) error {
+	input, err := json.Marshal(hookInput{Event: event.Type, Record: event.Record, OldIp: event.OldIp, networkFacts: facts})
+	if err != nil {
+		return fmt.Errorf("error encoding the input of the hook '%v': %v", h.name, err)
+	}
+
+	// The output goes to a file instead of a pipe so a background process
+	// started by the command can't keep the hook waiting after the timeout.
+	output, err := ioutil.TempFile("", "ecs-sidecar-hook")
+	if err != nil {
+		return fmt.Errorf("error creating the output file of the hook '%v': %v", h.name, err)
+	}
+	defer os.Remove(output.Name())
+	defer output.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.command)
+	cmd.Env = append(os.Environ(), hookEnv(event, facts)...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	start := time.Now()
+	err = cmd.Run()
+
+	h.logOutput(output)
+
+	var exitErr *exec.ExitError
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("hook '%v' timed out after %v", h.name, h.timeout)
+	case errors.As(err, &exitErr):
+		return fmt.Errorf("hook '%v' exited with code %v", h.name, exitErr.ExitCode())
+	case err != nil:
+		return fmt.Errorf("error running the hook '%v': %v", h.name, err)
+	}
+
+	log.Printf("Hook '%v' finished in %v\n", h.name, time.Since(start).Round(time.Millisecond))
+
+	return nil
+}
+
+func (h *Hook) logOutput(output *os.File) {
+	content, err := ioutil.ReadFile(output.Name())
+	if err != nil {
+		log.Printf("Error reading the output of the hook '%v': %v\n", h.name, err)
+		return
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		if len(line) > 0 {
+			log.Printf("[%v] %v\n", h.name, line)
+		}
+	}
+}
+
+// publishHookEvent returns the event the on-publish hook runs with. Unlike the
+// notifications, the hook also runs when the record was already right, so a
+// task reusing the ip of the one it replaces still learns its ip is published.
+func publishHookEvent(outcome string, plan recordPlan, event lifecycleEvent) lifecycleEvent {
+	changedEvent, ok := publishEvent(outcome, plan, event)
+	if ok {
+		return changedEvent
+	}
+
+	event.Type = eventUnchanged
+	event.OldIp = strings.Join(plan.OldIps, ",")
+	event.NewIp = strings.Join(plan.NewIps, ",")
+
+	return event
+}
+
+func hookEnv(event lifecycleEvent, facts networkFacts) []string {
+	env := strings.Split(strings.TrimRight(string(facts.env()), "\n"), "\n")
+
+	return append(env,
+		"HOOK_EVENT="+event.Type,
+		"RECORD="+event.Record,
+		"OLD_IP="+event.OldIp,
+	)
+}
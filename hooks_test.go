@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Hook_Run_PassesFactsInEnvAndStdin(t *testing.T) {
+	dir := t.TempDir()
+	command := "env > " + filepath.Join(dir, "env") + " && cat > " + filepath.Join(dir, "stdin")
+
+	err := NewHook("on-publish", command, time.Second).Run(context.TODO(), testChangedEvent, testFacts)
+
+	assert.Nil(t, err)
+
+	env, _ := ioutil.ReadFile(filepath.Join(dir, "env"))
+	lines := strings.Split(string(env), "\n")
+
+	assert.Contains(t, lines, "PUBLIC_IP="+testFacts.PublicIp)
+	assert.Contains(t, lines, "TASK_ID="+testFacts.TaskId)
+	assert.Contains(t, lines, "HOOK_EVENT=changed")
+	assert.Contains(t, lines, "RECORD=app.example.com")
+	assert.Contains(t, lines, "OLD_IP=1.1.1.1")
+
+	stdin, _ := ioutil.ReadFile(filepath.Join(dir, "stdin"))
+
+	input := hookInput{}
+	err = json.Unmarshal(stdin, &input)
+
+	assert.Nil(t, err)
+	assert.Equal(t, hookInput{Event: eventChanged, Record: "app.example.com", OldIp: "1.1.1.1", networkFacts: testFacts}, input)
+}
+
+func Test_Hook_Run_ExitCode(t *testing.T) {
+	err := NewHook("on-publish", "echo failing; exit 3", time.Second).Run(context.TODO(), testChangedEvent, testFacts)
+
+	assert.EqualError(t, err, "hook 'on-publish' exited with code 3")
+}
+
+func Test_Hook_Run_Timeout(t *testing.T) {
+	start := time.Now()
+
+	err := NewHook("on-withdraw", "sleep 5", 50*time.Millisecond).Run(context.TODO(), testChangedEvent, testFacts)
+
+	assert.EqualError(t, err, "hook 'on-withdraw' timed out after 50ms")
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func Test_PublishHookEvent(t *testing.T) {
+	plan := recordPlan{OldIps: []string{"1.1.1.1"}, NewIps: []string{"2.2.2.2"}}
+
+	event := publishHookEvent(publishOutcomeUpdated, plan, lifecycleEvent{Record: "app.example.com"})
+
+	assert.Equal(t, lifecycleEvent{Type: eventChanged, Record: "app.example.com", OldIp: "1.1.1.1", NewIp: "2.2.2.2"}, event)
+
+	plan = recordPlan{OldIps: []string{"2.2.2.2"}, NewIps: []string{"2.2.2.2"}}
+
+	event = publishHookEvent(publishOutcomeUnchanged, plan, lifecycleEvent{Record: "app.example.com"})
+
+	assert.Equal(t, lifecycleEvent{Type: eventUnchanged, Record: "app.example.com", OldIp: "2.2.2.2", NewIp: "2.2.2.2"}, event)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const lambdaCommand = "lambda"
+
+type lambdaResponse struct {
+	TaskArn string `json:"taskArn"`
+	Status  string `json:"status"`
+	Outcome string `json:"outcome"`
+}
+
+// LambdaHandler handles the ECS task state change events sent by an
+// EventBridge rule straight to a Lambda function.
+type LambdaHandler struct {
+	handler *TaskEventHandler
+}
+
+func NewLambdaHandler(handler *TaskEventHandler) *LambdaHandler {
+	return &LambdaHandler{handler: handler}
+}
+
+func (l *LambdaHandler) HandleRequest(ctx context.Context, event taskStateChangeEvent) (lambdaResponse, error) {
+	outcome, err := l.handler.Handle(ctx, event)
+	if err != nil {
+		return lambdaResponse{}, fmt.Errorf("error handling event '%v' for task '%v': %v", event.Id, event.Detail.TaskArn, err)
+	}
+
+	return lambdaResponse{
+		TaskArn: event.Detail.TaskArn,
+		Status:  event.Detail.LastStatus,
+		Outcome: outcome,
+	}, nil
+}
+
+func isLambdaEnvironment() bool {
+	return len(os.Getenv("AWS_LAMBDA_RUNTIME_API")) > 0
+}
+
+// runLambda starts the Lambda runtime loop, or handles a single event read
+// from a json file when --event is given, to try the handler locally.
+func runLambda(ctx context.Context, cfg aws.Config, clusterName string, useServiceTags bool, defaults dnsSettings, args []string) {
+	flags := flag.NewFlagSet(lambdaCommand, flag.ExitOnError)
+	eventFile := flags.String("event", "", "json file with an event to handle locally")
+	flags.Parse(args)
+
+	route53Api := NewRetryingRoute53Api(InitRoute53Api(cfg), defaultRoute53RetryPolicy(), newTokenBucket(route53RequestsPerSecond, route53RequestsBurst))
+
+	handler := NewLambdaHandler(NewTaskEventHandler(InitEcsApi(cfg), InitEc2Api(cfg), route53Api, clusterName, useServiceTags, defaults))
+
+	if len(*eventFile) == 0 {
+		lambda.Start(handler.HandleRequest)
+		return
+	}
+
+	response, err := invokeLambdaHandlerWithFile(ctx, handler, *eventFile)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	json.NewEncoder(os.Stdout).Encode(response)
+}
+
+func invokeLambdaHandlerWithFile(ctx context.Context, handler *LambdaHandler, path string) (lambdaResponse, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return lambdaResponse{}, fmt.Errorf("error reading event file '%v': %v", path, err)
+	}
+
+	event := taskStateChangeEvent{}
+
+	err = json.Unmarshal(content, &event)
+	if err != nil {
+		return lambdaResponse{}, fmt.Errorf("error decoding event file '%v': %v", path, err)
+	}
+
+	return handler.HandleRequest(ctx, event)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_InvokeLambdaHandlerWithFile_MissingFile(t *testing.T) {
+	handler := NewLambdaHandler(NewTaskEventHandler(NewMockedEcsApi(), NewMockedEc2Api(), NewMockedRoute53Api(), "cluster", false, dnsSettings{}))
+
+	result, err := invokeLambdaHandlerWithFile(context.TODO(), handler, "testdata/missing.json")
+
+	assert.Equal(t, lambdaResponse{}, result)
+	assert.EqualError(t, err, "error reading event file 'testdata/missing.json': open testdata/missing.json: no such file or directory")
+}
+
+func Test_LambdaHandler_HandleRequest_Error(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+
+	mockedEcsApi.On("DescribeTasks", ctx, expectedFixtureDescribeTasksInput()).Return(nil, fmt.Errorf("some error"))
+
+	handler := NewLambdaHandler(NewTaskEventHandler(mockedEcsApi, NewMockedEc2Api(), NewMockedRoute53Api(), "cluster", false, dnsSettings{}))
+
+	result, err := invokeLambdaHandlerWithFile(ctx, handler, "testdata/task_state_change_stopped.json")
+
+	assert.Equal(t, lambdaResponse{}, result)
+	assert.EqualError(t, err, "error handling event '8f07d9e4-3e4a-6c2b-1a2f-4e4e2c0b1d2a' for task '"+fixtureTaskArn+"': error describing task with arn '"+fixtureTaskArn+"': some error")
+
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func Test_LambdaHandler_HandleRequest_TaskRunning_UsesEniFromEvent(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedEc2Api := NewMockedEc2Api()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	task := ecsTypes.Task{TaskArn: aws.String(fixtureTaskArn)}
+
+	mockedEcsApi.On("DescribeTasks", ctx, expectedFixtureDescribeTasksInput()).Return(&ecs.DescribeTasksOutput{Tasks: []ecsTypes.Task{task}}, nil)
+
+	describeNetworkInterfacesOutput := &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []ec2Types.NetworkInterface{networkInterfaceWithIp("eni-1234", "1.1.1.1")},
+	}
+
+	mockedEc2Api.On("DescribeNetworkInterfaces", ctx, &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []string{"eni-1234"}}).Return(describeNetworkInterfacesOutput, nil)
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, fixtureListResourceRecordSetsInput()).Return(&route53.ListResourceRecordSetsOutput{}, nil)
+	mockOwnerRecord(mockedRoute53Api, "zoneId", "app.example.com", nil)
+
+	changeOutput := &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53Types.ChangeInfo{Status: route53Types.ChangeStatusPending},
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, mock.AnythingOfType("*route53.ChangeResourceRecordSetsInput")).Return(changeOutput, nil)
+	mockedEcsApi.On("TagResource", ctx, mock.AnythingOfType("*ecs.TagResourceInput")).Return(&ecs.TagResourceOutput{}, nil)
+	mockedEc2Api.On("CreateTags", ctx, mock.AnythingOfType("*ec2.CreateTagsInput")).Return(&ec2.CreateTagsOutput{}, nil)
+
+	handler := NewLambdaHandler(NewTaskEventHandler(mockedEcsApi, mockedEc2Api, mockedRoute53Api, "cluster", false, dnsSettings{Domain: "app.example.com", HostedZoneId: "zoneId", TTL: 300}))
+
+	result, err := invokeLambdaHandlerWithFile(ctx, handler, "testdata/task_state_change_running.json")
+
+	assert.Equal(t, lambdaResponse{TaskArn: fixtureTaskArn, Status: taskStatusRunning, Outcome: publishOutcomeCreated}, result)
+	assert.Nil(t, err)
+
+	mockedEcsApi.AssertExpectations(t)
+	mockedEc2Api.AssertExpectations(t)
+	mockedRoute53Api.AssertExpectations(t)
+}
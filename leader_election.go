@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+const (
+	leaseRecordPrefix = "_leader."
+	leaseRecordTTL    = 1
+
+	invalidChangeBatchErrorCode = "InvalidChangeBatch"
+
+	defaultLeaseDuration = 30 * time.Second
+)
+
+type lease struct {
+	Holder  string
+	Expires time.Time
+}
+
+// LeaderElector keeps a lease in a TXT record next to the shared record.
+// Route53 applies a change batch atomically and rejects a DELETE that doesn't
+// match the current record, so DELETE+CREATE works as a compare and swap.
+type LeaderElector struct {
+	route53Api    Route53Api
+	hostedZoneId  string
+	leaseName     string
+	identity      string
+	leaseDuration time.Duration
+	now           func() time.Time
+
+	mu      sync.Mutex
+	leading bool
+}
+
+func NewLeaderElector(route53Api Route53Api, hostedZoneId string, domain string, identity string, leaseDuration time.Duration) *LeaderElector {
+	return &LeaderElector{
+		route53Api:    route53Api,
+		hostedZoneId:  hostedZoneId,
+		leaseName:     leaseRecordPrefix + domain,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		now:           time.Now,
+	}
+}
+
+// TryAcquire takes the lease when it is free or expired, or renews it when
+// this replica already holds it. Losing the race against another replica is
+// not an error.
+func (e *LeaderElector) TryAcquire(ctx context.Context) (bool, error) {
+	current, err := getRecordSet(ctx, e.route53Api, e.hostedZoneId, e.leaseName, route53Types.RRTypeTxt)
+	if err != nil {
+		return false, err
+	}
+
+	now := e.now()
+
+	changes := []route53Types.Change{}
+
+	if current != nil {
+		currentLease, err := parseLeaseRecordSet(*current)
+		if err != nil {
+			log.Printf("Replacing the invalid lease '%v': %v\n", e.leaseName, err)
+		} else if currentLease.Holder != e.identity && now.Before(currentLease.Expires) {
+			return false, nil
+		}
+
+		changes = append(changes, route53Types.Change{Action: route53Types.ChangeActionDelete, ResourceRecordSet: current})
+	}
+
+	newLease := lease{Holder: e.identity, Expires: now.Add(e.leaseDuration)}
+	changes = append(changes, route53Types.Change{Action: route53Types.ChangeActionCreate, ResourceRecordSet: e.leaseRecordSet(newLease)})
+
+	err = e.changeLease(ctx, changes)
+	if err != nil {
+		if awsErrorCode(err) == invalidChangeBatchErrorCode {
+			log.Printf("Lost the race for the lease '%v'\n", e.leaseName)
+
+			return false, nil
+		}
+
+		return false, fmt.Errorf("error acquiring the lease '%v': %v", e.leaseName, err)
+	}
+
+	return true, nil
+}
+
+// Release deletes the lease when this replica holds it, so another replica
+// doesn't have to wait for it to expire.
+func (e *LeaderElector) Release(ctx context.Context) error {
+	current, err := getRecordSet(ctx, e.route53Api, e.hostedZoneId, e.leaseName, route53Types.RRTypeTxt)
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		return nil
+	}
+
+	currentLease, err := parseLeaseRecordSet(*current)
+	if err != nil || currentLease.Holder != e.identity {
+		return nil
+	}
+
+	err = e.changeLease(ctx, []route53Types.Change{{Action: route53Types.ChangeActionDelete, ResourceRecordSet: current}})
+	if err != nil && awsErrorCode(err) != invalidChangeBatchErrorCode {
+		return fmt.Errorf("error releasing the lease '%v': %v", e.leaseName, err)
+	}
+
+	return nil
+}
+
+// IsLeader reports whether runAsLeader holds the lease and has published the
+// record, so other callers don't change the record when it doesn't.
+func (e *LeaderElector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.leading
+}
+
+func (e *LeaderElector) setLeader(leading bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.leading = leading
+}
+
+func (e *LeaderElector) changeLease(ctx context.Context, changes []route53Types.Change) error {
+	changeResourceRecordSetsInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch:  &route53Types.ChangeBatch{Changes: changes},
+		HostedZoneId: aws.String(e.hostedZoneId),
+	}
+
+	_, err := e.route53Api.ChangeResourceRecordSets(ctx, changeResourceRecordSetsInput)
+
+	return err
+}
+
+func (e *LeaderElector) leaseRecordSet(l lease) *route53Types.ResourceRecordSet {
+	return &route53Types.ResourceRecordSet{
+		Name: aws.String(e.leaseName),
+		Type: route53Types.RRTypeTxt,
+		TTL:  aws.Int64(leaseRecordTTL),
+		ResourceRecords: []route53Types.ResourceRecord{
+			{Value: aws.String(formatLease(l))},
+		},
+	}
+}
+
+func formatLease(l lease) string {
+	return fmt.Sprintf("\"holder=%v expires=%v\"", l.Holder, l.Expires.Unix())
+}
+
+func parseLeaseRecordSet(recordSet route53Types.ResourceRecordSet) (lease, error) {
+	if len(recordSet.ResourceRecords) != 1 {
+		return lease{}, fmt.Errorf("expected one value, got %v", len(recordSet.ResourceRecords))
+	}
+
+	value := strings.Trim(aws.ToString(recordSet.ResourceRecords[0].Value), "\"")
+
+	l := lease{}
+	for _, field := range strings.Fields(value) {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		switch parts[0] {
+		case "holder":
+			l.Holder = parts[1]
+		case "expires":
+			expires, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return lease{}, fmt.Errorf("invalid expiration '%v'", parts[1])
+			}
+
+			l.Expires = time.Unix(expires, 0)
+		}
+	}
+
+	if len(l.Holder) == 0 || l.Expires.IsZero() {
+		return lease{}, fmt.Errorf("invalid lease '%v'", value)
+	}
+
+	return l, nil
+}
+
+// runAsLeader publishes the shared record whenever this replica becomes the
+// leader, renewing the lease until the context is done.
+func runAsLeader(ctx context.Context, elector *LeaderElector, publish func(ctx context.Context) error) {
+	isLeader := false
+	renewInterval := elector.leaseDuration / 3
+
+	for {
+		acquired, err := elector.TryAcquire(ctx)
+		if err != nil {
+			log.Printf("Error in leader election: %v\n", err)
+			acquired = false
+		}
+
+		if acquired && !isLeader {
+			log.Printf("Became the leader of '%v'\n", elector.leaseName)
+
+			err = publish(ctx)
+			if err != nil {
+				log.Printf("Error publishing as leader: %v\n", err)
+				acquired = false
+			}
+		} else if !acquired && isLeader {
+			log.Printf("Lost the leadership of '%v'\n", elector.leaseName)
+		}
+
+		isLeader = acquired
+		elector.setLeader(isLeader)
+
+		select {
+		case <-ctx.Done():
+			elector.setLeader(false)
+
+			if isLeader {
+				err = elector.Release(context.Background())
+				if err != nil {
+					log.Printf("Error releasing the lease: %v\n", err)
+				}
+			}
+			return
+		case <-time.After(renewInterval):
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testLeaseNow = time.Unix(1646128800, 0)
+
+func expectedLeaseListInput() *route53.ListResourceRecordSetsInput {
+	return &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String("zoneId"),
+		StartRecordName: aws.String("_leader.domain"),
+		StartRecordType: route53Types.RRTypeTxt,
+		MaxItems:        aws.Int32(1),
+	}
+}
+
+func leaseRecordSetFor(holder string, expires time.Time) route53Types.ResourceRecordSet {
+	return route53Types.ResourceRecordSet{
+		Name:            aws.String("_leader.domain."),
+		Type:            route53Types.RRTypeTxt,
+		TTL:             aws.Int64(leaseRecordTTL),
+		ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String(formatLease(lease{Holder: holder, Expires: expires}))}},
+	}
+}
+
+func newTestLeaderElector(route53Api Route53Api) *LeaderElector {
+	elector := NewLeaderElector(route53Api, "zoneId", "domain", "me", 30*time.Second)
+	elector.now = func() time.Time {
+		return testLeaseNow
+	}
+
+	return elector
+}
+
+func expectedLeaseChangeInput(changes ...route53Types.Change) *route53.ChangeResourceRecordSetsInput {
+	return &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch:  &route53Types.ChangeBatch{Changes: changes},
+		HostedZoneId: aws.String("zoneId"),
+	}
+}
+
+func newLeaseChange(elector *LeaderElector) route53Types.Change {
+	return route53Types.Change{
+		Action:            route53Types.ChangeActionCreate,
+		ResourceRecordSet: elector.leaseRecordSet(lease{Holder: "me", Expires: testLeaseNow.Add(30 * time.Second)}),
+	}
+}
+
+func Test_LeaderElector_TryAcquire_ListError(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	elector := newTestLeaderElector(mockedRoute53Api)
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedLeaseListInput()).Return(nil, fmt.Errorf("some error")).Once()
+
+	result, err := elector.TryAcquire(ctx)
+
+	assert.False(t, result)
+	assert.EqualError(t, err, "error listing record sets in Route53 hosted zone 'zoneId' with domain '_leader.domain': some error")
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_LeaderElector_TryAcquire_NoLease(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	elector := newTestLeaderElector(mockedRoute53Api)
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedLeaseListInput()).Return(&route53.ListResourceRecordSetsOutput{}, nil).Once()
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, expectedLeaseChangeInput(newLeaseChange(elector))).Return(&route53.ChangeResourceRecordSetsOutput{}, nil).Once()
+
+	result, err := elector.TryAcquire(ctx)
+
+	assert.True(t, result)
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_LeaderElector_TryAcquire_HeldByOther(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	elector := newTestLeaderElector(mockedRoute53Api)
+
+	current := leaseRecordSetFor("other", testLeaseNow.Add(10*time.Second))
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedLeaseListInput()).Return(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{current}}, nil).Once()
+
+	result, err := elector.TryAcquire(ctx)
+
+	assert.False(t, result)
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_LeaderElector_TryAcquire_ExpiredLease(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	elector := newTestLeaderElector(mockedRoute53Api)
+
+	current := leaseRecordSetFor("other", testLeaseNow.Add(-time.Second))
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedLeaseListInput()).Return(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{current}}, nil).Once()
+
+	deleteChange := route53Types.Change{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &current}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, expectedLeaseChangeInput(deleteChange, newLeaseChange(elector))).Return(&route53.ChangeResourceRecordSetsOutput{}, nil).Once()
+
+	result, err := elector.TryAcquire(ctx)
+
+	assert.True(t, result)
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_LeaderElector_TryAcquire_LostRace(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	elector := newTestLeaderElector(mockedRoute53Api)
+
+	current := leaseRecordSetFor("me", testLeaseNow.Add(10*time.Second))
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedLeaseListInput()).Return(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{current}}, nil).Once()
+
+	invalidChangeBatch := &smithy.GenericAPIError{Code: invalidChangeBatchErrorCode, Message: "Tried to delete resource record set but it was not found"}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, mock.AnythingOfType("*route53.ChangeResourceRecordSetsInput")).Return(nil, invalidChangeBatch).Once()
+
+	result, err := elector.TryAcquire(ctx)
+
+	assert.False(t, result)
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_LeaderElector_Release_HeldByMe(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	elector := newTestLeaderElector(mockedRoute53Api)
+
+	current := leaseRecordSetFor("me", testLeaseNow.Add(10*time.Second))
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedLeaseListInput()).Return(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{current}}, nil).Once()
+
+	deleteChange := route53Types.Change{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &current}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, expectedLeaseChangeInput(deleteChange)).Return(&route53.ChangeResourceRecordSetsOutput{}, nil).Once()
+
+	err := elector.Release(ctx)
+
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_ParseLeaseRecordSet_Invalid(t *testing.T) {
+	recordSet := route53Types.ResourceRecordSet{
+		ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("\"holder=me expires=soon\"")}},
+	}
+
+	_, err := parseLeaseRecordSet(recordSet)
+
+	assert.EqualError(t, err, "invalid expiration 'soon'")
+}
+
+func Test_RunAsLeader_PublishesAndReleases(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	mockedRoute53Api := NewMockedRoute53Api()
+	elector := newTestLeaderElector(mockedRoute53Api)
+
+	acquired := leaseRecordSetFor("me", testLeaseNow.Add(30*time.Second))
+
+	mockedRoute53Api.On("ListResourceRecordSets", mock.Anything, expectedLeaseListInput()).Return(&route53.ListResourceRecordSetsOutput{}, nil).Once()
+	mockedRoute53Api.On("ChangeResourceRecordSets", mock.Anything, expectedLeaseChangeInput(newLeaseChange(elector))).Return(&route53.ChangeResourceRecordSetsOutput{}, nil).Once()
+	mockedRoute53Api.On("ListResourceRecordSets", mock.Anything, expectedLeaseListInput()).Return(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{acquired}}, nil).Once()
+
+	deleteChange := route53Types.Change{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &acquired}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", mock.Anything, expectedLeaseChangeInput(deleteChange)).Return(&route53.ChangeResourceRecordSetsOutput{}, nil).Once()
+
+	published := 0
+	runAsLeader(ctx, elector, func(ctx context.Context) error {
+		published++
+		return nil
+	})
+
+	assert.Equal(t, 1, published)
+	assert.False(t, elector.IsLeader())
+
+	mockedRoute53Api.AssertExpectations(t)
+}
@@ -3,25 +3,109 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
 	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	publishOutcomeCreated   = "created"
+	publishOutcomeUpdated   = "updated"
+	publishOutcomeUnchanged = "unchanged"
+	publishOutcomeDeleted   = "deleted"
+	publishOutcomeFailed    = "failed"
+
+	sharedRecordSetAttempts = 5
 )
 
 type taskMetadata struct {
-	TaskARN string
+	TaskARN    string
+	Containers []containerMetadata
 }
 
 func main() {
+	// seeds the jitter of the Route53 retries, which the concurrent callers of
+	// the shared RetryingRoute53Api take from the goroutine safe rand functions
+	rand.Seed(time.Now().UnixNano())
+
+	err := run()
+	if errors.Is(err, errPlanHasChanges) {
+		os.Exit(planExitCodeChanges)
+	}
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// run returns the errors instead of exiting, so the deferred cleanups, like
+// flushing the traces, also run when the sidecar fails.
+func run() error {
+	dryRun := flag.Bool("dry-run", false, "print the intended DNS changes without applying them")
+	output := flag.String("output", planOutputText, "dry run output format (text or json)")
+	leaderElection := flag.Bool("leader-election", len(os.Getenv("LEADER_ELECTION")) > 0, "only publish the record while this task holds the leader lease")
+	leaseDuration := flag.Duration("lease-duration", defaultLeaseDuration, "how long the leader lease lasts without renewal")
+	healthContainers := flag.String("health-containers", os.Getenv("HEALTH_CONTAINERS"), "comma separated list of the containers that must be HEALTHY before publishing")
+	healthInterval := flag.Duration("health-interval", defaultHealthInterval, "time between container health checks")
+	healthyThreshold := flag.Int("healthy-threshold", defaultHealthyThreshold, "consecutive healthy checks needed to publish the record")
+	unhealthyThreshold := flag.Int("unhealthy-threshold", defaultUnhealthyThreshold, "consecutive unhealthy checks needed to withdraw the record")
+	probeSpecs := flag.String("probes", os.Getenv("PROBES"), "comma separated list of probes against the app (tcp://host:port, http://host:port/path or grpc://host:port/service)")
+	probeTimeout := flag.Duration("probe-timeout", defaultProbeTimeout, "timeout of each probe")
+	probeHttpStatus := flag.Int("probe-http-status", defaultProbeHttpStatus, "status expected from the http probes")
+	factsDir := flag.String("facts-dir", os.Getenv("FACTS_DIR"), "directory where the network facts are written for the app containers (disabled when empty)")
+	statusAddr := flag.String("status-addr", os.Getenv("STATUS_ADDR"), "address of the local status api and metrics, like 127.0.0.1:8053 (disabled when empty)")
+	emfEnabled := flag.Bool("emf", len(os.Getenv("EMF")) > 0, "write the metrics to stdout in CloudWatch Embedded Metric Format, except in dry runs")
+	emfNamespace := flag.String("emf-namespace", defaultEmfNamespace, "CloudWatch namespace of the emf metrics")
+	otlpEndpoint := flag.String("otlp-endpoint", os.Getenv("OTLP_ENDPOINT"), "host:port of the OTLP gRPC collector the traces are exported to (disabled when empty)")
+	webhooksConfig := flag.String("webhooks-config", os.Getenv("WEBHOOKS_CONFIG"), "json file with the webhooks notified of the record changes")
+	snsTopicArn := flag.String("sns-topic-arn", os.Getenv("SNS_TOPIC_ARN"), "sns topic where the record events are published (disabled when empty)")
+	eventBus := flag.String("event-bus", os.Getenv("EVENT_BUS"), "name or arn of the EventBridge bus where the record events are put (disabled when empty)")
+	onPublish := flag.String("on-publish", os.Getenv("ON_PUBLISH"), "shell command run after every successful publish, with HOOK_EVENT published, changed or unchanged")
+	onWithdraw := flag.String("on-withdraw", os.Getenv("ON_WITHDRAW"), "shell command run when the ip is withdrawn from the record")
+	hookTimeout := flag.Duration("hook-timeout", defaultHookTimeout, "time the hook commands can run before being killed")
+	hookRequired := flag.Bool("hook-required", len(os.Getenv("HOOK_REQUIRED")) > 0, "exit the sidecar when a hook command fails")
+	dnsPlugin := flag.String("dns-plugin", os.Getenv("DNS_PLUGIN"), "executable managing the record through the dns plugin protocol instead of Route53")
+	dnsPluginTimeout := flag.Duration("dns-plugin-timeout", defaultDnsPluginTimeout, "time each dns plugin call can run before being killed")
+	dnsPluginZone := flag.String("dns-plugin-zone", os.Getenv("DNS_PLUGIN_ZONE"), "zone sent to the dns plugin when the task has no hosted zone tag (the parent domain of the record when empty)")
+	eipPool := flag.String("eip-pool", os.Getenv("EIP_POOL"), "tag (key=value) of the Elastic IPs the task claims one from, publishing it instead of the ephemeral ip (disabled when empty)")
+	egress := flag.Bool("egress", len(os.Getenv("EGRESS")) > 0, "discover the nat gateway ip the task calls out from, allowing tasks in private subnets")
+	egressDomain := flag.String("egress-domain", os.Getenv("EGRESS_DOMAIN"), "record where the egress ip is published, implies -egress (disabled when empty)")
+	allowlistSpec := flag.String("allowlist", os.Getenv("ALLOWLIST"), "managed prefix list (pl-id) or security group (sg-id or sg-id:protocol:port) the task ip is added to while it runs (disabled when empty)")
+	targetGroupArn := flag.String("target-group-arn", os.Getenv("TARGET_GROUP_ARN"), "ELBv2 target group the task private ip is registered in while it runs (disabled when empty)")
+	targetGroupRoleArn := flag.String("target-group-role-arn", os.Getenv("TARGET_GROUP_ROLE_ARN"), "role assumed to manage the target group, for target groups in another account")
+	targetPort := flag.String("target-port", os.Getenv("TARGET_PORT"), "port of the task registered in the target group")
+	targetAvailabilityZone := flag.String("target-availability-zone", os.Getenv("TARGET_AVAILABILITY_ZONE"), "availability zone of the target, use all when the task is outside the vpc of the target group")
+	deregistrationTimeout := flag.Duration("deregistration-timeout", defaultDeregistrationTimeout, "time to wait for the target to drain on shutdown, keep it under the container stopTimeout")
+	daemon := flag.Bool("daemon", false, "keep checking the task health, withdrawing the record while it is unhealthy")
+	flag.Parse()
+
 	clusterName := os.Getenv("CLUSTER_NAME")
-	domain := os.Getenv("DOMAIN")
+	useServiceTags := len(os.Getenv("USE_SERVICE_TAGS")) > 0
+	defaultSettings := dnsSettings{
+		Domain: os.Getenv("DOMAIN"),
+		TTL:    defaultTTL,
+		Owner:  recordOwnerId(ownerKindTask, clusterName),
+	}
 
 	for _, item := range os.Environ() {
 		log.Println(item)
@@ -30,38 +114,544 @@ func main() {
 	ctx := context.TODO()
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
-		log.Fatalf("error loading the default config: %v", err)
+		return fmt.Errorf("error loading the default config: %v", err)
 	}
 
-	metadataEndpointClient := InitMetadataEndpointClient()
+	command := flag.Arg(0)
+	commandArgs := []string{}
+	if flag.NArg() > 1 {
+		commandArgs = flag.Args()[1:]
+	}
 
-	taskArn, err := getCurrentTaskArn(metadataEndpointClient)
+	if isLambdaEnvironment() {
+		command = lambdaCommand
+	}
+
+	switch command {
+	case controllerCommand:
+		runController(ctx, cfg, clusterName, defaultSettings, commandArgs)
+		return nil
+	case sqsCommand:
+		runSqsConsumer(ctx, cfg, clusterName, useServiceTags, defaultSettings, commandArgs)
+		return nil
+	case gcCommand:
+		runGarbageCollector(ctx, cfg, clusterName, defaultSettings, commandArgs)
+		return nil
+	case lambdaCommand:
+		runLambda(ctx, cfg, clusterName, useServiceTags, defaultSettings, commandArgs)
+		return nil
+	}
+
+	// a dry run publishes nothing, and its plan is printed to stdout, where
+	// the emf lines would get mixed with it
+	var emf *EmfEmitter
+	if *emfEnabled && !*dryRun {
+		emf = NewEmfEmitter(os.Stdout, *emfNamespace, emfDimensions{Cluster: clusterName, Domain: defaultSettings.Domain})
+	}
+
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(registry, emf)
+
+	shutdownTracing, err := initTracing(ctx, *otlpEndpoint)
 	if err != nil {
-		log.Fatal(err.Error())
+		return err
+	}
+	defer shutdownTracing(context.Background())
+
+	if len(*otlpEndpoint) > 0 {
+		otelaws.AppendMiddlewares(&cfg.APIOptions)
 	}
 
-	ecsApi := InitEcsApi(cfg)
+	discoverCtx, discoverSpan := startSpan(ctx, "discover")
+	// ends the span when the discovery fails, ending it twice is a no-op
+	defer discoverSpan.End()
 
-	eni, err := getTaskEni(ctx, ecsApi, clusterName, taskArn)
+	metadataEndpointClient := NewInstrumentedMetadataEndpointClient(InitMetadataEndpointClient(), metrics)
+
+	_, span := startSpan(discoverCtx, metadataStep)
+	taskArn, err := getCurrentTaskArn(metadataEndpointClient)
+	endSpan(span, err)
 	if err != nil {
-		log.Fatal(err.Error())
+		return err
 	}
 
-	ec2Api := InitEc2Api(cfg)
+	discoverSpan.SetAttributes(taskArnAttributeKey.String(taskArn))
 
-	publicIp, err := getPublicIpFromTaskEni(ctx, ec2Api, eni)
+	ecsApi := NewInstrumentedEcsApi(InitEcsApi(cfg), metrics)
+
+	stepCtx, span := startSpan(discoverCtx, "getTask")
+	task, err := getTask(stepCtx, ecsApi, clusterName, taskArn)
+	endSpan(span, err)
 	if err != nil {
-		log.Fatal(err.Error())
+		return err
 	}
 
-	route53Api := InitRoute53Api(cfg)
+	eni, err := getTaskEni(task)
+	if err != nil {
+		return err
+	}
 
-	status, err := changeRoute53RecordSet(ctx, route53Api, domain, publicIp)
+	stepCtx, span = startSpan(discoverCtx, "getDnsSettings")
+	settings, err := getDnsSettings(stepCtx, ecsApi, clusterName, task, useServiceTags, defaultSettings)
+	endSpan(span, err)
 	if err != nil {
-		log.Fatal(err.Error())
+		return err
 	}
 
-	log.Printf("Change Route53 recordset status: %v\n", status)
+	discoverSpan.SetAttributes(domainAttributeKey.String(settings.Domain))
+
+	if emf != nil {
+		emf.SetDimensions(emfDimensions{Cluster: clusterName, Service: getTaskServiceName(task), Domain: settings.Domain})
+	}
+
+	ec2Api := NewInstrumentedEc2Api(InitEc2Api(cfg), metrics)
+
+	discoverEgress := *egress || len(*egressDomain) > 0
+
+	// the egress record and the target group don't need a public ip, so tasks
+	// in private subnets can use them
+	stepCtx, span = startSpan(discoverCtx, "getPublicIpFromTaskEni")
+	publicIp, err := getPublicIpFromTaskEni(stepCtx, ec2Api, eni)
+	endSpan(span, err)
+	if err != nil && !((discoverEgress || len(*targetGroupArn) > 0) && errors.Is(err, errNoPublicIp)) {
+		return err
+	}
+
+	// a task with a public ip calls out from it, not through a nat gateway
+	egressIp := ""
+	if discoverEgress && len(publicIp) > 0 {
+		log.Println("The task has a public ip, skipping the egress discovery")
+	} else if discoverEgress {
+		stepCtx, span = startSpan(discoverCtx, "getEgressIpFromTaskEni")
+		egressIp, err = getEgressIpFromTaskEni(stepCtx, ec2Api, eni)
+		endSpan(span, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	var elasticIpPool *ElasticIpPool
+	if len(*eipPool) > 0 {
+		elasticIpPool, err = NewElasticIpPool(ec2Api, *eipPool)
+		if err != nil {
+			return err
+		}
+	}
+
+	route53Api := NewRetryingRoute53Api(NewInstrumentedRoute53Api(InitRoute53Api(cfg), metrics), defaultRoute53RetryPolicy(), newTokenBucket(route53RequestsPerSecond, route53RequestsBurst))
+
+	var dnsProvider DNSProvider = NewRoute53DNSProvider(route53Api)
+	if len(*dnsPlugin) > 0 {
+		dnsProvider = NewExecDNSProvider(*dnsPlugin, nil, *dnsPluginTimeout)
+		settings.HostedZoneId = getDnsPluginZone(settings, *dnsPluginZone)
+	} else {
+		stepCtx, span = startSpan(discoverCtx, "getHostedZoneId")
+		settings.HostedZoneId, err = getHostedZoneId(stepCtx, route53Api, settings)
+		endSpan(span, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	discoverSpan.End()
+
+	if *daemon && *leaderElection {
+		return fmt.Errorf("daemon mode can't be combined with leader election")
+	}
+
+	if len(*dnsPlugin) > 0 && *leaderElection {
+		return fmt.Errorf("the dns plugin can't be combined with leader election, which needs Route53")
+	}
+
+	// the gc of the cluster removes the ips of the nat gateways its tasks no
+	// longer go out through, so each cluster needs its own egress record
+	egressSettings := settings
+	egressSettings.Domain = *egressDomain
+	egressSettings.Owner = recordOwnerId(ownerKindEgress, clusterName)
+
+	hasPublicIp := len(publicIp) > 0
+	publishEgress := len(*egressDomain) > 0 && len(egressIp) > 0
+
+	if *dryRun {
+		if elasticIpPool != nil {
+			log.Println("Dry run, not claiming an elastic ip from the pool")
+		}
+
+		plans := []recordPlan{}
+
+		if hasPublicIp {
+			plan, err := dnsProvider.Plan(ctx, settings, []string{publicIp})
+			if err != nil {
+				return err
+			}
+
+			plans = append(plans, plan)
+		}
+
+		if publishEgress {
+			plan, err := planEgressIp(ctx, dnsProvider, egressSettings, egressIp)
+			if err != nil {
+				return err
+			}
+
+			plans = append(plans, plan)
+		}
+
+		changes := false
+		for _, plan := range plans {
+			err = printPlan(os.Stdout, plan, *output)
+			if err != nil {
+				return err
+			}
+
+			changes = changes || plan.hasChanges()
+		}
+
+		if changes {
+			return errPlanHasChanges
+		}
+
+		return nil
+	}
+
+	if publishEgress {
+		outcome, err := publishEgressIp(ctx, dnsProvider, egressSettings, egressIp)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Egress record '%v': %v\n", egressSettings.Domain, outcome)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// a task without a public ip still serves its status and facts
+	if !hasPublicIp {
+		log.Println("The task has no public ip, its record isn't published")
+	}
+
+	// the ip is claimed once the discovery is done, and its release is
+	// deferred right away, so a start failing after the claim gives it back
+	var claimedIp *elasticIp
+	if elasticIpPool != nil && hasPublicIp {
+		stepCtx, span := startSpan(ctx, "claimElasticIp")
+		claimed, err := elasticIpPool.Claim(stepCtx, eni)
+		endSpan(span, err)
+		if err != nil {
+			return err
+		}
+
+		claimedIp = &claimed
+		publicIp = claimed.PublicIp
+
+		defer func() {
+			err := elasticIpPool.Release(context.Background(), claimed)
+			if err != nil {
+				log.Println(err.Error())
+			}
+		}()
+	}
+
+	var allowlist Allowlist
+	if len(*allowlistSpec) > 0 && hasPublicIp {
+		allowlist, err = newAllowlist(ec2Api, *allowlistSpec)
+		if err != nil {
+			return err
+		}
+
+		allowlistEntry := newAllowlistEntry(publicIp, clusterName, taskIdFromArn(taskArn))
+
+		err = allowlist.Add(ctx, allowlistEntry)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			err := allowlist.Remove(context.Background(), allowlistEntry)
+			if err != nil {
+				log.Println(err.Error())
+			}
+		}()
+	}
+
+	// deferred last so the target drains before the rest of the shutdown
+	var targetGroup *TargetGroupRegistration
+	if len(*targetGroupArn) > 0 {
+		port, err := parseTargetPort(*targetPort)
+		if err != nil {
+			return err
+		}
+
+		privateIp := getTaskPrivateIp(task)
+		if len(privateIp) == 0 {
+			return fmt.Errorf("the task has no private ip to register in the target group")
+		}
+
+		elbv2Cfg := cfg.Copy()
+		if len(*targetGroupRoleArn) > 0 {
+			elbv2Cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), *targetGroupRoleArn))
+		}
+
+		targetGroup = NewTargetGroupRegistration(InitElbv2Api(elbv2Cfg), *targetGroupArn, port, *targetAvailabilityZone)
+
+		err = targetGroup.Register(ctx, privateIp)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			err := targetGroup.Deregister(context.Background(), privateIp, *deregistrationTimeout)
+			if err != nil {
+				log.Println(err.Error())
+			}
+		}()
+	}
+
+	if len(*factsDir) > 0 {
+		_, err = writeFacts(*factsDir, getNetworkFacts(task, publicIp, settings.Domain))
+		if err != nil {
+			return err
+		}
+
+		if *daemon && hasPublicIp {
+			go watchFacts(ctx, *factsDir, *healthInterval, func(ctx context.Context) (networkFacts, error) {
+				publicIp, err := getPublicIpFromTaskEni(ctx, ec2Api, eni)
+				if err != nil {
+					return networkFacts{}, err
+				}
+
+				return getNetworkFacts(task, publicIp, settings.Domain), nil
+			})
+		}
+	}
+
+	notifiers := []Notifier{}
+	if len(*webhooksConfig) > 0 {
+		webhooks, err := loadWebhooks(*webhooksConfig)
+		if err != nil {
+			return err
+		}
+
+		for _, webhook := range webhooks {
+			notifiers = append(notifiers, webhook)
+		}
+	}
+
+	if len(*snsTopicArn) > 0 {
+		notifiers = append(notifiers, NewSnsNotifier(InitSnsApi(cfg), *snsTopicArn))
+	}
+
+	if len(*eventBus) > 0 {
+		notifiers = append(notifiers, NewEventBridgeNotifier(InitEventBridgeApi(cfg), *eventBus))
+	}
+
+	newEvent := func(eventType string) lifecycleEvent {
+		return lifecycleEvent{Type: eventType, Time: time.Now(), TaskArn: taskArn, Cluster: clusterName, Record: settings.Domain, HostedZoneId: settings.HostedZoneId}
+	}
+
+	notifyFailure := func(ctx context.Context, err error) {
+		event := newEvent(eventFailed)
+		event.NewIp = publicIp
+		event.Error = err.Error()
+
+		notifyAll(ctx, notifiers, event)
+	}
+
+	// a required hook that fails stops the sidecar through the context, so
+	// the cleanups run, and its error is returned once everything stopped
+	hookErr := make(chan error, 1)
+
+	runHook := func(ctx context.Context, name string, command string, event lifecycleEvent) error {
+		if len(command) == 0 {
+			return nil
+		}
+
+		err := NewHook(name, command, *hookTimeout).Run(ctx, event, getNetworkFacts(task, publicIp, settings.Domain))
+		if err != nil {
+			if *hookRequired {
+				select {
+				case hookErr <- err:
+				default:
+				}
+				stop()
+
+				return err
+			}
+
+			log.Printf("Error running the hook: %v\n", err)
+		}
+
+		return nil
+	}
+
+	publish := func(ctx context.Context) error {
+		plan, err := dnsProvider.Plan(ctx, settings, []string{publicIp})
+
+		outcome := ""
+		if err == nil {
+			outcome, err = dnsProvider.Apply(ctx, settings, plan)
+		}
+
+		if err != nil {
+			metrics.RecordPublishFailed()
+			notifyFailure(ctx, err)
+
+			return err
+		}
+
+		metrics.RecordPublished(settings.Domain, publicIp, outcome)
+
+		// tagged even when the record was already right, like for a new task
+		// reusing the ip of the previous one
+		err = tagPublishedResources(ctx, ecsApi, ec2Api, taskArn, eni, settings, publicIp, time.Now())
+		if err != nil {
+			log.Printf("Error tagging published resources: %v\n", err)
+		}
+
+		event, ok := publishEvent(outcome, plan, newEvent(""))
+		if ok {
+			notifyAll(ctx, notifiers, event)
+		}
+
+		return runHook(ctx, "on-publish", *onPublish, publishHookEvent(outcome, plan, newEvent("")))
+	}
+
+	spanAttributes := []attribute.KeyValue{taskArnAttributeKey.String(taskArn), domainAttributeKey.String(settings.Domain)}
+	publish = tracedAction("publish", publish, spanAttributes...)
+
+	withdraw := func(ctx context.Context) error {
+		outcome, err := dnsProvider.Withdraw(ctx, settings, publicIp)
+		if err != nil {
+			notifyFailure(ctx, err)
+
+			return err
+		}
+
+		metrics.RecordWithdrawn()
+
+		if outcome != publishOutcomeUnchanged {
+			event := newEvent(eventWithdrawn)
+			event.OldIp = publicIp
+
+			notifyAll(ctx, notifiers, event)
+
+			return runHook(ctx, "on-withdraw", *onWithdraw, event)
+		}
+
+		return nil
+	}
+	withdraw = tracedAction("withdraw", withdraw, spanAttributes...)
+
+	if !hasPublicIp {
+		noop := func(ctx context.Context) error {
+			return nil
+		}
+
+		publish, withdraw = noop, noop
+	}
+
+	healthChecks := []healthCheck{}
+
+	containers := parseList(*healthContainers)
+	if len(containers) > 0 {
+		healthChecks = append(healthChecks, containersHealthCheck(metadataEndpointClient, containers))
+	}
+
+	probes := []Probe{}
+	for _, spec := range parseList(*probeSpecs) {
+		probe, err := parseProbe(spec, *probeHttpStatus)
+		if err != nil {
+			return err
+		}
+
+		probes = append(probes, probe)
+	}
+
+	var probeSet *ProbeSet
+	if len(probes) > 0 {
+		probeSet = NewProbeSet(probes, *probeTimeout)
+		healthChecks = append(healthChecks, probeSet.Check)
+	}
+
+	if *daemon && len(healthChecks) == 0 {
+		return fmt.Errorf("daemon mode needs containers or probes to check")
+	}
+
+	status := sidecarStatus{
+		TaskArn:   taskArn,
+		Eni:       eni,
+		PublicIps: []string{},
+		EgressIp:  egressIp,
+		Records:   []publishedRecord{},
+	}
+
+	if hasPublicIp {
+		status.PublicIps = append(status.PublicIps, publicIp)
+		status.Records = append(status.Records, publishedRecord{Name: settings.Domain, HostedZoneId: settings.HostedZoneId, TTL: settings.TTL})
+	}
+
+	if publishEgress {
+		status.Records = append(status.Records, publishedRecord{Name: egressSettings.Domain, HostedZoneId: egressSettings.HostedZoneId, TTL: egressSettings.TTL})
+	}
+	statusServer := NewStatusServer(status, probeSet, registry, publish, withdraw)
+
+	var elector *LeaderElector
+	if *leaderElection {
+		elector = NewLeaderElector(route53Api, settings.HostedZoneId, settings.Domain, taskArn, *leaseDuration)
+		statusServer.RequireLeader(elector.IsLeader)
+	}
+
+	if len(*statusAddr) > 0 {
+		go func() {
+			err := statusServer.ListenAndServe(ctx, *statusAddr)
+			if err != nil {
+				log.Printf("Error serving the status api: %v\n", err)
+			}
+		}()
+	}
+
+	var watcher *HealthWatcher
+	if len(healthChecks) > 0 {
+		watcher = NewHealthWatcher(allHealthChecks(healthChecks...), *healthyThreshold, *unhealthyThreshold)
+	}
+
+	switch {
+	case !hasPublicIp:
+		// the egress record, published above, is the only one of the task
+	case *leaderElection:
+		if watcher != nil {
+			noop := func(ctx context.Context) error {
+				return nil
+			}
+
+			err = runHealthGated(ctx, watcher, *healthInterval, false, noop, noop)
+			if err != nil {
+				break
+			}
+		}
+
+		runAsLeader(ctx, elector, statusServer.Publish)
+	case watcher != nil:
+		err = runHealthGated(ctx, watcher, *healthInterval, *daemon, statusServer.Publish, statusServer.Withdraw)
+	default:
+		err = statusServer.Publish(ctx)
+	}
+
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+
+	if len(*statusAddr) > 0 || claimedIp != nil || allowlist != nil || targetGroup != nil {
+		<-ctx.Done()
+	}
+
+	select {
+	case err := <-hookErr:
+		return err
+	default:
+		return nil
+	}
 }
 
 func getCurrentTaskArn(client MetadataEndpointClient) (string, error) {
@@ -86,18 +676,27 @@ func getCurrentTaskArn(client MetadataEndpointClient) (string, error) {
 	return taskArn, nil
 }
 
-func getTaskEni(ctx context.Context, ecsApi EcsApi, clusterName string, taskArn string) (string, error) {
+func getTask(ctx context.Context, ecsApi EcsApi, clusterName string, taskArn string) (ecsTypes.Task, error) {
 	describeTasksInput := &ecs.DescribeTasksInput{
 		Cluster: aws.String(clusterName),
 		Tasks:   []string{taskArn},
+		Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags},
 	}
 
 	describeTasksOutput, err := ecsApi.DescribeTasks(ctx, describeTasksInput)
 	if err != nil {
-		return "", fmt.Errorf("error describing task with arn '%v': %v", taskArn, err)
+		return ecsTypes.Task{}, fmt.Errorf("error describing task with arn '%v': %v", taskArn, err)
+	}
+
+	if len(describeTasksOutput.Tasks) == 0 {
+		return ecsTypes.Task{}, fmt.Errorf("task with arn '%v' not found", taskArn)
 	}
 
-	for _, attachment := range describeTasksOutput.Tasks[0].Attachments {
+	return describeTasksOutput.Tasks[0], nil
+}
+
+func getTaskEni(task ecsTypes.Task) (string, error) {
+	for _, attachment := range task.Attachments {
 		for _, detail := range attachment.Details {
 			if *detail.Name == "networkInterfaceId" {
 				log.Printf("The eni of the first task is '%v'", *detail.Value)
@@ -120,44 +719,290 @@ func getPublicIpFromTaskEni(ctx context.Context, ec2Api Ec2Api, taskEni string)
 		return "", fmt.Errorf("error describing network interface with id '%v': %v", taskEni, err)
 	}
 
-	publicIp := *describeNetworkInterfacesOutput.NetworkInterfaces[0].Association.PublicIp
+	association := describeNetworkInterfacesOutput.NetworkInterfaces[0].Association
+	if association == nil || association.PublicIp == nil {
+		return "", fmt.Errorf("network interface with id '%v': %w", taskEni, errNoPublicIp)
+	}
+
+	publicIp := *association.PublicIp
 
 	log.Printf("Public ip: %v\n", publicIp)
 
 	return publicIp, nil
 }
 
-func changeRoute53RecordSet(ctx context.Context, route53Api Route53Api, domain string, publicIp string) (route53Types.ChangeStatus, error) {
-	listHostedZonesOutput, err := route53Api.ListHostedZones(ctx, &route53.ListHostedZonesInput{})
+// publishRoute53RecordSet only sends the UPSERT when the current record differs
+// from the wanted one, to save Route53 API calls on large deployments.
+func publishRoute53RecordSet(ctx context.Context, route53Api Route53Api, settings dnsSettings, publicIps []string) (string, error) {
+	plan, err := planRoute53RecordSet(ctx, route53Api, settings, publicIps)
 	if err != nil {
-		return "", fmt.Errorf("error listing hosted zones: %v", err)
+		return "", err
+	}
+
+	return applyRoute53Plan(ctx, route53Api, settings, plan)
+}
+
+func applyRoute53Plan(ctx context.Context, route53Api Route53Api, settings dnsSettings, plan recordPlan) (string, error) {
+	if !plan.hasChanges() {
+		log.Printf("Route53 record '%v' already points to '%v' with ttl %v, nothing to change\n", settings.Domain, strings.Join(plan.NewIps, ","), settings.TTL)
+
+		return publishOutcomeUnchanged, nil
+	}
+
+	status, err := changeRoute53RecordSet(ctx, route53Api, settings, plan.NewIps)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Change Route53 recordset status: %v\n", status)
+
+	if plan.Action == planActionCreate {
+		return publishOutcomeCreated, nil
+	}
+
+	return publishOutcomeUpdated, nil
+}
+
+// addIpToRoute53RecordSet adds the ip to the ones already in the record, for
+// records shared by several tasks.
+func addIpToRoute53RecordSet(ctx context.Context, route53Api Route53Api, settings dnsSettings, publicIp string) (string, error) {
+	return updateSharedRecordSet(ctx, route53Api, settings, func(current *route53Types.ResourceRecordSet) (*route53Types.ResourceRecordSet, bool) {
+		publicIps := recordSetIps(current)
+		for _, ip := range publicIps {
+			if ip == publicIp {
+				log.Printf("Route53 record '%v' already contains '%v', nothing to change\n", settings.Domain, publicIp)
+
+				return nil, false
+			}
+		}
+
+		return aRecordSet(settings.Domain, settings.TTL, append(publicIps, publicIp)), true
+	})
+}
+
+// withdrawIpFromRoute53RecordSet removes the ip from the record, deleting the
+// record when no ips are left. Withdrawing an ip that is not in the record is a no-op.
+func withdrawIpFromRoute53RecordSet(ctx context.Context, route53Api Route53Api, settings dnsSettings, publicIp string) (string, error) {
+	return updateSharedRecordSet(ctx, route53Api, settings, func(current *route53Types.ResourceRecordSet) (*route53Types.ResourceRecordSet, bool) {
+		found := false
+		remainingIps := []string{}
+		for _, ip := range recordSetIps(current) {
+			if ip == publicIp {
+				found = true
+			} else {
+				remainingIps = append(remainingIps, ip)
+			}
+		}
+
+		if !found {
+			log.Printf("Route53 record '%v' does not contain '%v', nothing to withdraw\n", settings.Domain, publicIp)
+
+			return nil, false
+		}
+
+		if len(remainingIps) == 0 {
+			return nil, true
+		}
+
+		return aRecordSet(settings.Domain, aws.ToInt64(current.TTL), remainingIps), true
+	})
+}
+
+// updateSharedRecordSet replaces the record with the one returned by update,
+// deleting it when update returns nil. The record is replaced with DELETE and
+// CREATE, like the lease of the LeaderElector, so when another task changed
+// it in the meantime Route53 rejects the batch and the update is retried on
+// the new record, instead of dropping the ip of the other task.
+func updateSharedRecordSet(ctx context.Context, route53Api Route53Api, settings dnsSettings, update func(current *route53Types.ResourceRecordSet) (*route53Types.ResourceRecordSet, bool)) (string, error) {
+	for attempt := 1; ; attempt++ {
+		current, err := getCurrentRecordSet(ctx, route53Api, settings)
+		if err != nil {
+			return "", err
+		}
+
+		desired, changed := update(current)
+		if !changed {
+			return publishOutcomeUnchanged, nil
+		}
+
+		err = swapRoute53RecordSet(ctx, route53Api, settings, current, desired)
+		if err == nil {
+			switch {
+			case desired == nil:
+				return publishOutcomeDeleted, nil
+			case current == nil:
+				return publishOutcomeCreated, nil
+			default:
+				return publishOutcomeUpdated, nil
+			}
+		}
+
+		if awsErrorCode(err) != invalidChangeBatchErrorCode || attempt == sharedRecordSetAttempts {
+			return "", fmt.Errorf("error changing the record set '%v' in Route53 hosted zone '%v': %v", settings.Domain, settings.HostedZoneId, err)
+		}
+
+		log.Printf("Route53 record '%v' was changed by another task, retrying\n", settings.Domain)
+	}
+}
+
+// swapRoute53RecordSet deletes current and creates desired in one batch. The
+// owner record is claimed along with desired, or deleted along with current
+// when there is no desired record.
+func swapRoute53RecordSet(ctx context.Context, route53Api Route53Api, settings dnsSettings, current *route53Types.ResourceRecordSet, desired *route53Types.ResourceRecordSet) error {
+	changes := []route53Types.Change{}
+
+	if current != nil {
+		changes = append(changes, route53Types.Change{Action: route53Types.ChangeActionDelete, ResourceRecordSet: current})
 	}
 
-	hostedZoneId := listHostedZonesOutput.HostedZones[0].Id
+	if desired != nil {
+		changes = append(changes, route53Types.Change{Action: route53Types.ChangeActionCreate, ResourceRecordSet: desired})
+
+		ownerChanges, err := claimRecordChanges(ctx, route53Api, settings.HostedZoneId, settings)
+		if err != nil {
+			return err
+		}
+
+		changes = append(changes, ownerChanges...)
+	} else {
+		ownerSet, err := getRecordSet(ctx, route53Api, settings.HostedZoneId, ownerRecordName(aws.ToString(current.Name)), route53Types.RRTypeTxt)
+		if err != nil {
+			return err
+		}
+
+		if ownerSet != nil {
+			changes = append(changes, route53Types.Change{Action: route53Types.ChangeActionDelete, ResourceRecordSet: ownerSet})
+		}
+	}
 
 	changeResourceRecordSetsInput := &route53.ChangeResourceRecordSetsInput{
-		ChangeBatch: &route53Types.ChangeBatch{
-			Changes: []route53Types.Change{
-				{
-					Action: "UPSERT",
-					ResourceRecordSet: &route53Types.ResourceRecordSet{
-						Type: route53Types.RRTypeA,
-						Name: aws.String(domain),
-						TTL:  aws.Int64(300),
-						ResourceRecords: []route53Types.ResourceRecord{
-							{Value: aws.String(publicIp)},
-						},
-					},
-				},
+		ChangeBatch:  &route53Types.ChangeBatch{Changes: changes},
+		HostedZoneId: aws.String(settings.HostedZoneId),
+	}
+
+	_, err := route53Api.ChangeResourceRecordSets(ctx, changeResourceRecordSetsInput)
+
+	return err
+}
+
+func aRecordSet(domain string, ttl int64, publicIps []string) *route53Types.ResourceRecordSet {
+	resourceRecords := []route53Types.ResourceRecord{}
+	for _, publicIp := range publicIps {
+		resourceRecords = append(resourceRecords, route53Types.ResourceRecord{Value: aws.String(publicIp)})
+	}
+
+	return &route53Types.ResourceRecordSet{
+		Type:            route53Types.RRTypeA,
+		Name:            aws.String(domain),
+		TTL:             aws.Int64(ttl),
+		ResourceRecords: resourceRecords,
+	}
+}
+
+func changeRoute53RecordSet(ctx context.Context, route53Api Route53Api, settings dnsSettings, publicIps []string) (route53Types.ChangeStatus, error) {
+	hostedZoneId, err := getHostedZoneId(ctx, route53Api, settings)
+	if err != nil {
+		return "", err
+	}
+
+	resourceRecords := []route53Types.ResourceRecord{}
+	for _, publicIp := range publicIps {
+		resourceRecords = append(resourceRecords, route53Types.ResourceRecord{Value: aws.String(publicIp)})
+	}
+
+	domain := settings.Domain
+
+	changes := []route53Types.Change{
+		{
+			Action: "UPSERT",
+			ResourceRecordSet: &route53Types.ResourceRecordSet{
+				Type: route53Types.RRTypeA,
+				Name: aws.String(domain),
+				TTL:  aws.Int64(settings.TTL),
+				ResourceRecords: resourceRecords,
 			},
 		},
-		HostedZoneId: hostedZoneId,
+	}
+
+	// the owner record goes in the same batch, so the A record never exists
+	// without it
+	ownerChanges, err := claimRecordChanges(ctx, route53Api, hostedZoneId, settings)
+	if err != nil {
+		return "", err
+	}
+
+	changeResourceRecordSetsInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: append(changes, ownerChanges...),
+		},
+		HostedZoneId: aws.String(hostedZoneId),
 	}
 
 	changeResourceRecordSetsOutput, err := route53Api.ChangeResourceRecordSets(ctx, changeResourceRecordSetsInput)
 	if err != nil {
-		return "", fmt.Errorf("error changing the resouce set in Route53 hosted zone '%v' with domain '%v': %v", *hostedZoneId, domain, err)
+		return "", fmt.Errorf("error changing the resouce set in Route53 hosted zone '%v' with domain '%v': %v", hostedZoneId, domain, err)
 	}
 
 	return changeResourceRecordSetsOutput.ChangeInfo.Status, nil
 }
+
+func getHostedZoneId(ctx context.Context, route53Api Route53Api, settings dnsSettings) (string, error) {
+	if len(settings.HostedZoneId) > 0 {
+		return settings.HostedZoneId, nil
+	}
+
+	listHostedZonesOutput, err := route53Api.ListHostedZones(ctx, &route53.ListHostedZonesInput{})
+	if err != nil {
+		return "", fmt.Errorf("error listing hosted zones: %v", err)
+	}
+
+	if len(listHostedZonesOutput.HostedZones) == 0 {
+		return "", fmt.Errorf("no hosted zones found")
+	}
+
+	return *listHostedZonesOutput.HostedZones[0].Id, nil
+}
+
+func getCurrentRecordSet(ctx context.Context, route53Api Route53Api, settings dnsSettings) (*route53Types.ResourceRecordSet, error) {
+	return getRecordSet(ctx, route53Api, settings.HostedZoneId, settings.Domain, route53Types.RRTypeA)
+}
+
+func getRecordSet(ctx context.Context, route53Api Route53Api, hostedZoneId string, name string, recordType route53Types.RRType) (*route53Types.ResourceRecordSet, error) {
+	listResourceRecordSetsInput := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(hostedZoneId),
+		StartRecordName: aws.String(name),
+		StartRecordType: recordType,
+		MaxItems:        aws.Int32(1),
+	}
+
+	listResourceRecordSetsOutput, err := route53Api.ListResourceRecordSets(ctx, listResourceRecordSetsInput)
+	if err != nil {
+		return nil, fmt.Errorf("error listing record sets in Route53 hosted zone '%v' with domain '%v': %v", hostedZoneId, name, err)
+	}
+
+	for _, recordSet := range listResourceRecordSetsOutput.ResourceRecordSets {
+		if recordSet.Type == recordType && sameRecordName(*recordSet.Name, name) {
+			return &recordSet, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func sameRecordName(a string, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
+
+func recordSetIps(recordSet *route53Types.ResourceRecordSet) []string {
+	ips := []string{}
+
+	if recordSet == nil {
+		return ips
+	}
+
+	for _, resourceRecord := range recordSet.ResourceRecords {
+		ips = append(ips, *resourceRecord.Value)
+	}
+
+	return ips
+}
@@ -17,7 +17,9 @@ import (
 	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/aws/aws-sdk-go-v2/service/route53"
 	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/smithy-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestMain(m *testing.M) {
@@ -59,65 +61,95 @@ func Test_GetCurrentTaskArn_Ok(t *testing.T) {
 	mockedMetadataEndpointClient.AssertExpectations(t)
 }
 
-func Test_GetTaskEni_DescribeTasks_EniNotFound(t *testing.T) {
+func Test_GetTask_DescribeTasks_Error(t *testing.T) {
 	ctx := context.TODO()
 	mockedEcsApi := NewMockedEcsApi()
 
 	describeTasksInput := &ecs.DescribeTasksInput{
 		Cluster: aws.String("cluster"),
 		Tasks:   []string{"taskArn"},
+		Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags},
 	}
 
-	describeTasksOutput := &ecs.DescribeTasksOutput{
-		Tasks: []ecsTypes.Task{
-			{},
-		},
+	mockedEcsApi.On("DescribeTasks", ctx, describeTasksInput).Return(nil, fmt.Errorf("some error"))
+
+	_, err := getTask(ctx, mockedEcsApi, "cluster", "taskArn")
+
+	assert.EqualError(t, err, "error describing task with arn 'taskArn': some error")
+
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func Test_GetTask_DescribeTasks_NotFound(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+
+	describeTasksInput := &ecs.DescribeTasksInput{
+		Cluster: aws.String("cluster"),
+		Tasks:   []string{"taskArn"},
+		Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags},
 	}
 
-	mockedEcsApi.On("DescribeTasks", ctx, describeTasksInput).Return(describeTasksOutput, nil)
+	mockedEcsApi.On("DescribeTasks", ctx, describeTasksInput).Return(&ecs.DescribeTasksOutput{}, nil)
 
-	result, err := getTaskEni(ctx, mockedEcsApi, "cluster", "taskArn")
+	_, err := getTask(ctx, mockedEcsApi, "cluster", "taskArn")
 
-	assert.Empty(t, result)
-	assert.EqualError(t, err, "eni not found")
+	assert.EqualError(t, err, "task with arn 'taskArn' not found")
 
 	mockedEcsApi.AssertExpectations(t)
 }
 
-func Test_GetTaskEni_DescribeTasks_Ok(t *testing.T) {
+func Test_GetTask_DescribeTasks_Ok(t *testing.T) {
 	ctx := context.TODO()
 	mockedEcsApi := NewMockedEcsApi()
 
 	describeTasksInput := &ecs.DescribeTasksInput{
 		Cluster: aws.String("cluster"),
 		Tasks:   []string{"taskArn"},
+		Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags},
 	}
 
+	task := ecsTypes.Task{TaskArn: aws.String("taskArn")}
+
 	describeTasksOutput := &ecs.DescribeTasksOutput{
-		Tasks: []ecsTypes.Task{
+		Tasks: []ecsTypes.Task{task},
+	}
+
+	mockedEcsApi.On("DescribeTasks", ctx, describeTasksInput).Return(describeTasksOutput, nil)
+
+	result, err := getTask(ctx, mockedEcsApi, "cluster", "taskArn")
+
+	assert.Equal(t, task, result)
+	assert.Nil(t, err)
+
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func Test_GetTaskEni_EniNotFound(t *testing.T) {
+	result, err := getTaskEni(ecsTypes.Task{})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "eni not found")
+}
+
+func Test_GetTaskEni_Ok(t *testing.T) {
+	task := ecsTypes.Task{
+		Attachments: []ecsTypes.Attachment{
 			{
-				Attachments: []ecsTypes.Attachment{
+				Details: []ecsTypes.KeyValuePair{
 					{
-						Details: []ecsTypes.KeyValuePair{
-							{
-								Name:  aws.String("networkInterfaceId"),
-								Value: aws.String("taskEni"),
-							},
-						},
+						Name:  aws.String("networkInterfaceId"),
+						Value: aws.String("taskEni"),
 					},
 				},
 			},
 		},
 	}
 
-	mockedEcsApi.On("DescribeTasks", ctx, describeTasksInput).Return(describeTasksOutput, nil)
-
-	result, err := getTaskEni(ctx, mockedEcsApi, "cluster", "taskArn")
+	result, err := getTaskEni(task)
 
 	assert.Equal(t, "taskEni", result)
 	assert.Nil(t, err)
-
-	mockedEcsApi.AssertExpectations(t)
 }
 
 func Test_ChangeRoute53RecordSet_ListHostedZones_Error(t *testing.T) {
@@ -126,7 +158,7 @@ func Test_ChangeRoute53RecordSet_ListHostedZones_Error(t *testing.T) {
 
 	mockedRoute53Api.On("ListHostedZones", ctx, &route53.ListHostedZonesInput{}).Return(nil, fmt.Errorf("some error")).Once()
 
-	result, err := changeRoute53RecordSet(ctx, mockedRoute53Api, "domain", "ip")
+	result, err := changeRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", TTL: 300}, []string{"ip"})
 
 	assert.Empty(t, result)
 	assert.EqualError(t, err, "error listing hosted zones: some error")
@@ -169,7 +201,7 @@ func Test_ChangeRoute53RecordSet_ChangeResourceRecordSets_Error(t *testing.T) {
 
 	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, changeInput).Return(nil, fmt.Errorf("some error")).Once()
 
-	result, err := changeRoute53RecordSet(ctx, mockedRoute53Api, "domain", "ip")
+	result, err := changeRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", TTL: 300}, []string{"ip"})
 
 	assert.Empty(t, result)
 	assert.EqualError(t, err, "error changing the resouce set in Route53 hosted zone 'hostedZoneId' with domain 'domain': some error")
@@ -218,7 +250,7 @@ func Test_ChangeRoute53RecordSet_ChangeResourceRecordSets_Ok(t *testing.T) {
 
 	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, changeInput).Return(changeResourceRecordSetsOutput, nil).Once()
 
-	result, err := changeRoute53RecordSet(ctx, mockedRoute53Api, "domain", "ip")
+	result, err := changeRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", TTL: 300}, []string{"ip"})
 
 	assert.Equal(t, route53Types.ChangeStatusPending, result)
 	assert.Nil(t, err)
@@ -226,6 +258,45 @@ func Test_ChangeRoute53RecordSet_ChangeResourceRecordSets_Ok(t *testing.T) {
 	mockedRoute53Api.AssertExpectations(t)
 }
 
+func Test_ChangeRoute53RecordSet_WithHostedZoneId_Ok(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	changeInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{
+					Action: "UPSERT",
+					ResourceRecordSet: &route53Types.ResourceRecordSet{
+						Type: route53Types.RRTypeA,
+						Name: aws.String("domain"),
+						TTL:  aws.Int64(60),
+						ResourceRecords: []route53Types.ResourceRecord{
+							{Value: aws.String("ip")},
+						},
+					},
+				},
+			},
+		},
+		HostedZoneId: aws.String("zoneFromSettings"),
+	}
+
+	changeResourceRecordSetsOutput := &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53Types.ChangeInfo{
+			Status: route53Types.ChangeStatusInsync,
+		},
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, changeInput).Return(changeResourceRecordSetsOutput, nil).Once()
+
+	result, err := changeRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", HostedZoneId: "zoneFromSettings", TTL: 60}, []string{"ip"})
+
+	assert.Equal(t, route53Types.ChangeStatusInsync, result)
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
 func Test_GetPublicIpFromTaskEni_DescribeNetworkInterfaces_Error(t *testing.T) {
 	ctx := context.TODO()
 	mockedEc2Api := NewMockedEc2Api()
@@ -271,3 +342,200 @@ func Test_GetPublicIpFromTaskEni_DescribeNetworkInterfaces_Ok(t *testing.T) {
 
 	mockedEc2Api.AssertExpectations(t)
 }
+
+func Test_PublishRoute53RecordSet_ListResourceRecordSets_Error(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(nil, fmt.Errorf("some error")).Once()
+
+	result, err := publishRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", HostedZoneId: "zoneId", TTL: 300}, []string{"ip"})
+
+	assert.Empty(t, result)
+	assert.EqualError(t, err, "error listing record sets in Route53 hosted zone 'zoneId' with domain 'domain': some error")
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_PublishRoute53RecordSet_Unchanged(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	listOutput := &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets: []route53Types.ResourceRecordSet{
+			{
+				Name:            aws.String("domain."),
+				Type:            route53Types.RRTypeA,
+				TTL:             aws.Int64(300),
+				ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("ip")}},
+			},
+		},
+	}
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(listOutput, nil).Once()
+
+	result, err := publishRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", HostedZoneId: "zoneId", TTL: 300}, []string{"ip"})
+
+	assert.Equal(t, publishOutcomeUnchanged, result)
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_PublishRoute53RecordSet_Created(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(&route53.ListResourceRecordSetsOutput{}, nil).Once()
+
+	changeOutput := &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53Types.ChangeInfo{Status: route53Types.ChangeStatusPending},
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, mock.AnythingOfType("*route53.ChangeResourceRecordSetsInput")).Return(changeOutput, nil).Once()
+
+	result, err := publishRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", HostedZoneId: "zoneId", TTL: 300}, []string{"ip"})
+
+	assert.Equal(t, publishOutcomeCreated, result)
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_PublishRoute53RecordSet_Updated(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	listOutput := &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets: []route53Types.ResourceRecordSet{
+			{
+				Name:            aws.String("domain."),
+				Type:            route53Types.RRTypeA,
+				TTL:             aws.Int64(300),
+				ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("oldIp")}},
+			},
+		},
+	}
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(listOutput, nil).Once()
+
+	changeOutput := &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53Types.ChangeInfo{Status: route53Types.ChangeStatusPending},
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, mock.AnythingOfType("*route53.ChangeResourceRecordSetsInput")).Return(changeOutput, nil).Once()
+
+	result, err := publishRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", HostedZoneId: "zoneId", TTL: 300}, []string{"ip"})
+
+	assert.Equal(t, publishOutcomeUpdated, result)
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_WithdrawIpFromRoute53RecordSet_NotInRecord(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(&route53.ListResourceRecordSetsOutput{}, nil).Once()
+
+	result, err := withdrawIpFromRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", HostedZoneId: "zoneId"}, "ip")
+
+	assert.Equal(t, publishOutcomeUnchanged, result)
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_WithdrawIpFromRoute53RecordSet_KeepsOtherIps(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	currentRecordSet := route53Types.ResourceRecordSet{
+		Name:            aws.String("domain."),
+		Type:            route53Types.RRTypeA,
+		TTL:             aws.Int64(60),
+		ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("ip")}, {Value: aws.String("otherIp")}},
+	}
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{currentRecordSet}}, nil).Once()
+
+	changeInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{
+					Action:            route53Types.ChangeActionDelete,
+					ResourceRecordSet: &currentRecordSet,
+				},
+				{
+					Action: route53Types.ChangeActionCreate,
+					ResourceRecordSet: &route53Types.ResourceRecordSet{
+						Type: route53Types.RRTypeA,
+						Name: aws.String("domain"),
+						TTL:  aws.Int64(60),
+						ResourceRecords: []route53Types.ResourceRecord{
+							{Value: aws.String("otherIp")},
+						},
+					},
+				},
+			},
+		},
+		HostedZoneId: aws.String("zoneId"),
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, changeInput).Return(&route53.ChangeResourceRecordSetsOutput{}, nil).Once()
+
+	result, err := withdrawIpFromRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", HostedZoneId: "zoneId"}, "ip")
+
+	assert.Equal(t, publishOutcomeUpdated, result)
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_AddIpToRoute53RecordSet_RetriesConcurrentChange(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	otherRecordSet := route53Types.ResourceRecordSet{
+		Name:            aws.String("domain."),
+		Type:            route53Types.RRTypeA,
+		TTL:             aws.Int64(60),
+		ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("otherIp")}},
+	}
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(&route53.ListResourceRecordSetsOutput{}, nil).Once()
+
+	createInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{Action: route53Types.ChangeActionCreate, ResourceRecordSet: aRecordSet("domain", 60, []string{"ip"})},
+			},
+		},
+		HostedZoneId: aws.String("zoneId"),
+	}
+
+	alreadyExists := &smithy.GenericAPIError{Code: invalidChangeBatchErrorCode, Message: "already exists"}
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, createInput).Return(nil, alreadyExists).Once()
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{otherRecordSet}}, nil).Once()
+
+	swapInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &otherRecordSet},
+				{Action: route53Types.ChangeActionCreate, ResourceRecordSet: aRecordSet("domain", 60, []string{"otherIp", "ip"})},
+			},
+		},
+		HostedZoneId: aws.String("zoneId"),
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, swapInput).Return(&route53.ChangeResourceRecordSetsOutput{}, nil).Once()
+
+	result, err := addIpToRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", HostedZoneId: "zoneId", TTL: 60}, "ip")
+
+	assert.Equal(t, publishOutcomeUpdated, result)
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "ecs_sidecar"
+
+	metricsOutcomeSuccess   = "success"
+	metricsOutcomeError     = "error"
+	metricsOutcomeThrottled = "throttled"
+
+	metadataStep = "metadata"
+)
+
+// Metrics counts the requests made by the sidecar and tracks what it has
+// published. Every metric is registered in the given registerer, and also
+// written as emf when an emitter is given.
+type Metrics struct {
+	requests    *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+	publishes   *prometheus.CounterVec
+	publishedIp *prometheus.GaugeVec
+	emf         *EmfEmitter
+	mu          sync.Mutex
+	lastSync    time.Time
+	now         func() time.Time
+}
+
+func NewMetrics(registerer prometheus.Registerer, emf *EmfEmitter) *Metrics {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Requests made by the sidecar, by step and outcome.",
+		}, []string{"step", "outcome"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of the requests made by the sidecar, by step.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"step"}),
+		publishes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "publishes_total",
+			Help:      "Publishes of the record, by outcome.",
+		}, []string{"outcome"}),
+		publishedIp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "published_ip_info",
+			Help:      "The ip currently published in each record.",
+		}, []string{"record", "ip"}),
+		emf: emf,
+		now: time.Now,
+	}
+
+	secondsSinceLastSync := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "seconds_since_last_sync",
+		Help:      "Seconds since the last successful sync, -1 before the first one.",
+	}, m.secondsSinceLastSync)
+
+	registerer.MustRegister(m.requests, m.latency, m.publishes, m.publishedIp, secondsSinceLastSync)
+
+	return m
+}
+
+func (m *Metrics) observe(step string, start time.Time, err error) {
+	outcome := metricsOutcomeSuccess
+	if err != nil {
+		outcome = metricsOutcomeError
+
+		if awsErrorCode(err) == throttlingErrorCode {
+			outcome = metricsOutcomeThrottled
+		}
+	}
+
+	latency := m.now().Sub(start)
+
+	m.requests.WithLabelValues(step, outcome).Inc()
+	m.latency.WithLabelValues(step).Observe(latency.Seconds())
+
+	if m.emf != nil {
+		m.emf.EmitLatency(step, latency)
+	}
+}
+
+// RecordPublished is called after the ip has been published in the record.
+func (m *Metrics) RecordPublished(record string, ip string, outcome string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastSync = m.now()
+	m.publishedIp.Reset()
+	m.publishedIp.WithLabelValues(record, ip).Set(1)
+	m.recordPublishOutcome(outcome)
+}
+
+func (m *Metrics) RecordPublishFailed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.recordPublishOutcome(publishOutcomeFailed)
+}
+
+func (m *Metrics) recordPublishOutcome(outcome string) {
+	m.publishes.WithLabelValues(outcome).Inc()
+
+	if m.emf != nil {
+		m.emf.EmitPublish(outcome)
+	}
+}
+
+// RecordWithdrawn is called after the ip has been removed from the record.
+func (m *Metrics) RecordWithdrawn() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastSync = m.now()
+	m.publishedIp.Reset()
+}
+
+func (m *Metrics) secondsSinceLastSync() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lastSync.IsZero() {
+		return -1
+	}
+
+	return m.now().Sub(m.lastSync).Seconds()
+}
+
+type InstrumentedEcsApi struct {
+	api     EcsApi
+	metrics *Metrics
+}
+
+func NewInstrumentedEcsApi(api EcsApi, metrics *Metrics) *InstrumentedEcsApi {
+	return &InstrumentedEcsApi{api: api, metrics: metrics}
+}
+
+func (a *InstrumentedEcsApi) DescribeTasks(ctx context.Context, params *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.DescribeTasks(ctx, params)
+	a.metrics.observe("DescribeTasks", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEcsApi) DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput) (*ecs.DescribeServicesOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.DescribeServices(ctx, params)
+	a.metrics.observe("DescribeServices", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEcsApi) TagResource(ctx context.Context, params *ecs.TagResourceInput) (*ecs.TagResourceOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.TagResource(ctx, params)
+	a.metrics.observe("TagResource", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEcsApi) ListTasks(ctx context.Context, params *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.ListTasks(ctx, params)
+	a.metrics.observe("ListTasks", start, err)
+
+	return output, err
+}
+
+type InstrumentedEc2Api struct {
+	api     Ec2Api
+	metrics *Metrics
+}
+
+func NewInstrumentedEc2Api(api Ec2Api, metrics *Metrics) *InstrumentedEc2Api {
+	return &InstrumentedEc2Api{api: api, metrics: metrics}
+}
+
+func (a *InstrumentedEc2Api) DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.DescribeNetworkInterfaces(ctx, params)
+	a.metrics.observe("DescribeNetworkInterfaces", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) CreateTags(ctx context.Context, params *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.CreateTags(ctx, params)
+	a.metrics.observe("CreateTags", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.DescribeAddresses(ctx, params)
+	a.metrics.observe("DescribeAddresses", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) AssociateAddress(ctx context.Context, params *ec2.AssociateAddressInput) (*ec2.AssociateAddressOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.AssociateAddress(ctx, params)
+	a.metrics.observe("AssociateAddress", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) DisassociateAddress(ctx context.Context, params *ec2.DisassociateAddressInput) (*ec2.DisassociateAddressOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.DisassociateAddress(ctx, params)
+	a.metrics.observe("DisassociateAddress", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.DescribeRouteTables(ctx, params)
+	a.metrics.observe("DescribeRouteTables", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) DescribeNatGateways(ctx context.Context, params *ec2.DescribeNatGatewaysInput) (*ec2.DescribeNatGatewaysOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.DescribeNatGateways(ctx, params)
+	a.metrics.observe("DescribeNatGateways", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) DescribeManagedPrefixLists(ctx context.Context, params *ec2.DescribeManagedPrefixListsInput) (*ec2.DescribeManagedPrefixListsOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.DescribeManagedPrefixLists(ctx, params)
+	a.metrics.observe("DescribeManagedPrefixLists", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) GetManagedPrefixListEntries(ctx context.Context, params *ec2.GetManagedPrefixListEntriesInput) (*ec2.GetManagedPrefixListEntriesOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.GetManagedPrefixListEntries(ctx, params)
+	a.metrics.observe("GetManagedPrefixListEntries", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) ModifyManagedPrefixList(ctx context.Context, params *ec2.ModifyManagedPrefixListInput) (*ec2.ModifyManagedPrefixListOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.ModifyManagedPrefixList(ctx, params)
+	a.metrics.observe("ModifyManagedPrefixList", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) DescribeSecurityGroupRules(ctx context.Context, params *ec2.DescribeSecurityGroupRulesInput) (*ec2.DescribeSecurityGroupRulesOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.DescribeSecurityGroupRules(ctx, params)
+	a.metrics.observe("DescribeSecurityGroupRules", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) AuthorizeSecurityGroupIngress(ctx context.Context, params *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.AuthorizeSecurityGroupIngress(ctx, params)
+	a.metrics.observe("AuthorizeSecurityGroupIngress", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) RevokeSecurityGroupIngress(ctx context.Context, params *ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.RevokeSecurityGroupIngress(ctx, params)
+	a.metrics.observe("RevokeSecurityGroupIngress", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedEc2Api) UpdateSecurityGroupRuleDescriptionsIngress(ctx context.Context, params *ec2.UpdateSecurityGroupRuleDescriptionsIngressInput) (*ec2.UpdateSecurityGroupRuleDescriptionsIngressOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.UpdateSecurityGroupRuleDescriptionsIngress(ctx, params)
+	a.metrics.observe("UpdateSecurityGroupRuleDescriptionsIngress", start, err)
+
+	return output, err
+}
+
+// InstrumentedRoute53Api goes below the RetryingRoute53Api, so every attempt
+// is counted and throttled ones are visible.
+type InstrumentedRoute53Api struct {
+	api     Route53Api
+	metrics *Metrics
+}
+
+func NewInstrumentedRoute53Api(api Route53Api, metrics *Metrics) *InstrumentedRoute53Api {
+	return &InstrumentedRoute53Api{api: api, metrics: metrics}
+}
+
+func (a *InstrumentedRoute53Api) ListHostedZones(ctx context.Context, params *route53.ListHostedZonesInput) (*route53.ListHostedZonesOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.ListHostedZones(ctx, params)
+	a.metrics.observe("ListHostedZones", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedRoute53Api) ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.ChangeResourceRecordSets(ctx, params)
+	a.metrics.observe("ChangeResourceRecordSets", start, err)
+
+	return output, err
+}
+
+func (a *InstrumentedRoute53Api) ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	start := a.metrics.now()
+	output, err := a.api.ListResourceRecordSets(ctx, params)
+	a.metrics.observe("ListResourceRecordSets", start, err)
+
+	return output, err
+}
+
+type InstrumentedMetadataEndpointClient struct {
+	client  MetadataEndpointClient
+	metrics *Metrics
+}
+
+func NewInstrumentedMetadataEndpointClient(client MetadataEndpointClient, metrics *Metrics) *InstrumentedMetadataEndpointClient {
+	return &InstrumentedMetadataEndpointClient{client: client, metrics: metrics}
+}
+
+func (c *InstrumentedMetadataEndpointClient) Get(url string) (resp *http.Response, err error) {
+	start := c.metrics.now()
+	resp, err = c.client.Get(url)
+	c.metrics.observe(metadataStep, start, err)
+
+	return resp, err
+}
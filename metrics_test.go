@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMetrics() (*Metrics, *prometheus.Registry, *time.Time) {
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(registry, nil)
+
+	now := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	metrics.now = func() time.Time {
+		return now
+	}
+
+	return metrics, registry, &now
+}
+
+func Test_Metrics_Register(t *testing.T) {
+	_, registry, _ := newTestMetrics()
+
+	count, err := testutil.GatherAndCount(registry)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	err = testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP ecs_sidecar_seconds_since_last_sync Seconds since the last successful sync, -1 before the first one.
+# TYPE ecs_sidecar_seconds_since_last_sync gauge
+ecs_sidecar_seconds_since_last_sync -1
+`))
+
+	assert.Nil(t, err)
+}
+
+func Test_InstrumentedEc2Api(t *testing.T) {
+	ctx := context.TODO()
+	metrics, _, _ := newTestMetrics()
+	mockedEc2Api := NewMockedEc2Api()
+
+	input := &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []string{"eni"}}
+
+	mockedEc2Api.On("DescribeNetworkInterfaces", ctx, input).Return(&ec2.DescribeNetworkInterfacesOutput{}, nil).Once()
+	mockedEc2Api.On("DescribeNetworkInterfaces", ctx, input).Return(nil, fmt.Errorf("some error")).Once()
+
+	api := NewInstrumentedEc2Api(mockedEc2Api, metrics)
+
+	_, err := api.DescribeNetworkInterfaces(ctx, input)
+	assert.Nil(t, err)
+
+	_, err = api.DescribeNetworkInterfaces(ctx, input)
+	assert.EqualError(t, err, "some error")
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("DescribeNetworkInterfaces", metricsOutcomeSuccess)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("DescribeNetworkInterfaces", metricsOutcomeError)))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.latency))
+
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_InstrumentedRoute53Api_Throttled(t *testing.T) {
+	ctx := context.TODO()
+	metrics, _, _ := newTestMetrics()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	input := &route53.ChangeResourceRecordSetsInput{}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, input).Return(nil, throttlingError()).Once()
+
+	api := NewInstrumentedRoute53Api(mockedRoute53Api, metrics)
+
+	_, err := api.ChangeResourceRecordSets(ctx, input)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("ChangeResourceRecordSets", metricsOutcomeThrottled)))
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_Metrics_RecordPublished(t *testing.T) {
+	metrics, registry, now := newTestMetrics()
+
+	metrics.RecordPublished("app.example.com", "1.1.1.1", publishOutcomeCreated)
+	*now = now.Add(90 * time.Second)
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP ecs_sidecar_published_ip_info The ip currently published in each record.
+# TYPE ecs_sidecar_published_ip_info gauge
+ecs_sidecar_published_ip_info{ip="1.1.1.1",record="app.example.com"} 1
+# HELP ecs_sidecar_publishes_total Publishes of the record, by outcome.
+# TYPE ecs_sidecar_publishes_total counter
+ecs_sidecar_publishes_total{outcome="created"} 1
+# HELP ecs_sidecar_seconds_since_last_sync Seconds since the last successful sync, -1 before the first one.
+# TYPE ecs_sidecar_seconds_since_last_sync gauge
+ecs_sidecar_seconds_since_last_sync 90
+`))
+
+	assert.Nil(t, err)
+
+	metrics.RecordWithdrawn()
+
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.publishedIp))
+}
+
+func Test_StatusServer_Metrics(t *testing.T) {
+	_, registry, _ := newTestMetrics()
+
+	statusServer := NewStatusServer(sidecarStatus{}, nil, registry, nil, nil)
+	server := httptest.NewServer(statusServer.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	eventPublished = "published"
+	eventChanged   = "changed"
+	eventWithdrawn = "withdrawn"
+	eventFailed    = "failed"
+	// eventUnchanged is only passed to the on-publish hook, the notifiers
+	// aren't told about publishes that changed nothing.
+	eventUnchanged = "unchanged"
+)
+
+// lifecycleEvent describes something that happened to the record of a task.
+type lifecycleEvent struct {
+	Type         string    `json:"type"`
+	Time         time.Time `json:"time"`
+	TaskArn      string    `json:"taskArn"`
+	Cluster      string    `json:"cluster"`
+	Record       string    `json:"record"`
+	HostedZoneId string    `json:"hostedZoneId"`
+	OldIp        string    `json:"oldIp,omitempty"`
+	NewIp        string    `json:"newIp,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Notifier sends the lifecycle events somewhere outside the sidecar.
+type Notifier interface {
+	Notify(ctx context.Context, event lifecycleEvent) error
+}
+
+// publishEvent returns the event for the outcome of a publish, or false when
+// nothing changed.
+func publishEvent(outcome string, plan recordPlan, event lifecycleEvent) (lifecycleEvent, bool) {
+	switch outcome {
+	case publishOutcomeCreated:
+		event.Type = eventPublished
+	case publishOutcomeUpdated:
+		event.Type = eventChanged
+	default:
+		return event, false
+	}
+
+	event.OldIp = strings.Join(plan.OldIps, ",")
+	event.NewIp = strings.Join(plan.NewIps, ",")
+
+	return event, true
+}
+
+// notifyAll sends the event to every notifier. A failing notifier doesn't
+// stop the others, nor the sidecar.
+func notifyAll(ctx context.Context, notifiers []Notifier, event lifecycleEvent) {
+	for _, notifier := range notifiers {
+		err := notifier.Notify(ctx, event)
+		if err != nil {
+			log.Printf("Error notifying the '%v' event: %v\n", event.Type, err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+const (
+	ownerRecordPrefix = "_owner."
+	ownerRecordTTL    = 300
+	ownerHeritage     = "ecs-sidecar"
+
+	ownerKindTask       = "task"
+	ownerKindController = "controller"
+	ownerKindEgress     = "egress"
+)
+
+// recordOwner is kept in a TXT record next to every A record published by the
+// sidecar. It marks the record as owned, says who keeps it (kind/cluster) and
+// persists since when the gc has seen each ip orphan.
+type recordOwner struct {
+	Id          string
+	OrphanSince map[string]time.Time
+}
+
+type ownedRecord struct {
+	RecordSet route53Types.ResourceRecordSet
+	OwnerSet  route53Types.ResourceRecordSet
+	Owner     recordOwner
+}
+
+func recordOwnerId(kind string, clusterName string) string {
+	return kind + "/" + clusterKey(clusterName)
+}
+
+func (o recordOwner) Kind() string {
+	return strings.SplitN(o.Id, "/", 2)[0]
+}
+
+func (o recordOwner) Cluster() string {
+	parts := strings.SplitN(o.Id, "/", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+
+	return parts[1]
+}
+
+func normalizeRecordName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func ownerRecordName(domain string) string {
+	return ownerRecordPrefix + domain
+}
+
+func ownerRecordSet(domain string, owner recordOwner) *route53Types.ResourceRecordSet {
+	resourceRecords := []route53Types.ResourceRecord{
+		{Value: aws.String(fmt.Sprintf("\"heritage=%v owner=%v\"", ownerHeritage, owner.Id))},
+	}
+
+	ips := []string{}
+	for ip := range owner.OrphanSince {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	for _, ip := range ips {
+		resourceRecords = append(resourceRecords, route53Types.ResourceRecord{Value: aws.String(fmt.Sprintf("\"orphan=%v since=%v\"", ip, owner.OrphanSince[ip].Unix()))})
+	}
+
+	return &route53Types.ResourceRecordSet{
+		Name:            aws.String(ownerRecordName(domain)),
+		Type:            route53Types.RRTypeTxt,
+		TTL:             aws.Int64(ownerRecordTTL),
+		ResourceRecords: resourceRecords,
+	}
+}
+
+// parseOwnerRecordSet returns false when the TXT record wasn't written by the
+// sidecar.
+func parseOwnerRecordSet(recordSet route53Types.ResourceRecordSet) (recordOwner, bool) {
+	owner := recordOwner{OrphanSince: map[string]time.Time{}}
+	owned := false
+
+	for _, resourceRecord := range recordSet.ResourceRecords {
+		fields := map[string]string{}
+		for _, field := range strings.Fields(strings.Trim(aws.ToString(resourceRecord.Value), "\"")) {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) == 2 {
+				fields[parts[0]] = parts[1]
+			}
+		}
+
+		if fields["heritage"] == ownerHeritage {
+			owned = true
+			owner.Id = fields["owner"]
+		}
+
+		if ip, ok := fields["orphan"]; ok {
+			since, err := strconv.ParseInt(fields["since"], 10, 64)
+			if err == nil {
+				owner.OrphanSince[ip] = time.Unix(since, 0)
+			}
+		}
+	}
+
+	return owner, owned
+}
+
+// claimRecordChanges returns the change marking the record as kept by
+// settings.Owner, keeping the orphan marks of the gc. Nothing changes when the
+// record already has that owner, or when no owner is set.
+func claimRecordChanges(ctx context.Context, route53Api Route53Api, hostedZoneId string, settings dnsSettings) ([]route53Types.Change, error) {
+	if len(settings.Owner) == 0 {
+		return nil, nil
+	}
+
+	current, err := getRecordSet(ctx, route53Api, hostedZoneId, ownerRecordName(settings.Domain), route53Types.RRTypeTxt)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := recordOwner{Id: settings.Owner}
+	if current != nil {
+		currentOwner, ok := parseOwnerRecordSet(*current)
+		if ok && currentOwner.Id == settings.Owner {
+			return nil, nil
+		}
+
+		if ok {
+			owner.OrphanSince = currentOwner.OrphanSince
+		}
+	}
+
+	return []route53Types.Change{{Action: route53Types.ChangeActionUpsert, ResourceRecordSet: ownerRecordSet(settings.Domain, owner)}}, nil
+}
+
+// claimRecord writes the owner record of a record that is already published,
+// when it doesn't have settings.Owner yet.
+func claimRecord(ctx context.Context, route53Api Route53Api, hostedZoneId string, settings dnsSettings) error {
+	changes, err := claimRecordChanges(ctx, route53Api, hostedZoneId, settings)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+
+	changeResourceRecordSetsInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch:  &route53Types.ChangeBatch{Changes: changes},
+		HostedZoneId: aws.String(hostedZoneId),
+	}
+
+	_, err = route53Api.ChangeResourceRecordSets(ctx, changeResourceRecordSetsInput)
+	if err != nil {
+		return fmt.Errorf("error claiming the record set '%v' in Route53 hosted zone '%v': %v", settings.Domain, hostedZoneId, err)
+	}
+
+	return nil
+}
+
+// listOwnedRecords returns the A records of the zone that have an owner record
+// written by the sidecar.
+func listOwnedRecords(ctx context.Context, route53Api Route53Api, hostedZoneId string) ([]ownedRecord, error) {
+	recordSets := map[string]route53Types.ResourceRecordSet{}
+	ownerSets := map[string]route53Types.ResourceRecordSet{}
+
+	listResourceRecordSetsInput := &route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(hostedZoneId),
+	}
+
+	for {
+		listResourceRecordSetsOutput, err := route53Api.ListResourceRecordSets(ctx, listResourceRecordSetsInput)
+		if err != nil {
+			return nil, fmt.Errorf("error listing record sets in Route53 hosted zone '%v': %v", hostedZoneId, err)
+		}
+
+		for _, recordSet := range listResourceRecordSetsOutput.ResourceRecordSets {
+			name := normalizeRecordName(aws.ToString(recordSet.Name))
+
+			switch {
+			case recordSet.Type == route53Types.RRTypeA:
+				recordSets[name] = recordSet
+			case recordSet.Type == route53Types.RRTypeTxt && strings.HasPrefix(name, ownerRecordPrefix):
+				ownerSets[strings.TrimPrefix(name, ownerRecordPrefix)] = recordSet
+			}
+		}
+
+		if !listResourceRecordSetsOutput.IsTruncated {
+			break
+		}
+
+		listResourceRecordSetsInput.StartRecordName = listResourceRecordSetsOutput.NextRecordName
+		listResourceRecordSetsInput.StartRecordType = listResourceRecordSetsOutput.NextRecordType
+		listResourceRecordSetsInput.StartRecordIdentifier = listResourceRecordSetsOutput.NextRecordIdentifier
+	}
+
+	names := []string{}
+	for name := range recordSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	records := []ownedRecord{}
+	for _, name := range names {
+		ownerSet, ok := ownerSets[name]
+		if !ok {
+			continue
+		}
+
+		owner, ok := parseOwnerRecordSet(ownerSet)
+		if !ok {
+			continue
+		}
+
+		records = append(records, ownedRecord{RecordSet: recordSets[name], OwnerSet: ownerSet, Owner: owner})
+	}
+
+	return records, nil
+}
+
+// deleteOwnedRecord deletes the A record and its owner record in one batch.
+func deleteOwnedRecord(ctx context.Context, route53Api Route53Api, hostedZoneId string, record ownedRecord) error {
+	recordSet := record.RecordSet
+	ownerSet := record.OwnerSet
+
+	changeResourceRecordSetsInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &recordSet},
+				{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &ownerSet},
+			},
+		},
+		HostedZoneId: aws.String(hostedZoneId),
+	}
+
+	_, err := route53Api.ChangeResourceRecordSets(ctx, changeResourceRecordSetsInput)
+	if err != nil {
+		return fmt.Errorf("error deleting the record set '%v' in Route53 hosted zone '%v': %v", aws.ToString(recordSet.Name), hostedZoneId, err)
+	}
+
+	return nil
+}
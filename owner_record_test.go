@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func ownerRecordInput(hostedZoneId string, domain string) *route53.ListResourceRecordSetsInput {
+	return &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(hostedZoneId),
+		StartRecordName: aws.String(ownerRecordName(domain)),
+		StartRecordType: route53Types.RRTypeTxt,
+		MaxItems:        aws.Int32(1),
+	}
+}
+
+// mockOwnerRecord expects the read of the owner record, returning it when
+// ownerSet is not nil.
+func mockOwnerRecord(mockedRoute53Api *MockedRoute53Api, hostedZoneId string, domain string, ownerSet *route53Types.ResourceRecordSet) {
+	output := &route53.ListResourceRecordSetsOutput{}
+	if ownerSet != nil {
+		output.ResourceRecordSets = []route53Types.ResourceRecordSet{*ownerSet}
+	}
+
+	mockedRoute53Api.On("ListResourceRecordSets", context.TODO(), ownerRecordInput(hostedZoneId, domain)).Return(output, nil).Once()
+}
+
+func ownerChange(domain string, owner recordOwner) route53Types.Change {
+	return route53Types.Change{Action: route53Types.ChangeActionUpsert, ResourceRecordSet: ownerRecordSet(domain, owner)}
+}
+
+func Test_OwnerRecordSet_RoundTrip(t *testing.T) {
+	owner := recordOwner{Id: "task/cluster", OrphanSince: map[string]time.Time{"2.2.2.2": time.Unix(200, 0), "1.1.1.1": time.Unix(100, 0)}}
+
+	recordSet := ownerRecordSet("domain", owner)
+
+	assert.Equal(t, "_owner.domain", aws.ToString(recordSet.Name))
+	assert.Equal(t, []route53Types.ResourceRecord{
+		{Value: aws.String("\"heritage=ecs-sidecar owner=task/cluster\"")},
+		{Value: aws.String("\"orphan=1.1.1.1 since=100\"")},
+		{Value: aws.String("\"orphan=2.2.2.2 since=200\"")},
+	}, recordSet.ResourceRecords)
+
+	result, ok := parseOwnerRecordSet(*recordSet)
+
+	assert.True(t, ok)
+	assert.Equal(t, owner, result)
+	assert.Equal(t, "task", result.Kind())
+	assert.Equal(t, "cluster", result.Cluster())
+}
+
+func Test_ParseOwnerRecordSet_NotOwned(t *testing.T) {
+	recordSet := route53Types.ResourceRecordSet{ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("\"v=spf1 -all\"")}}}
+
+	_, ok := parseOwnerRecordSet(recordSet)
+
+	assert.False(t, ok)
+}
+
+func Test_RecordOwnerId(t *testing.T) {
+	assert.Equal(t, "task/cluster", recordOwnerId(ownerKindTask, "arn:aws:ecs:eu-west-1:111122223333:cluster/cluster"))
+	assert.Equal(t, "controller/default", recordOwnerId(ownerKindController, ""))
+}
+
+func Test_ClaimRecordChanges_KeepsOrphanMarks(t *testing.T) {
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	current := ownerRecordSet("domain", recordOwner{Id: "controller/cluster", OrphanSince: map[string]time.Time{"1.1.1.1": time.Unix(100, 0)}})
+	mockOwnerRecord(mockedRoute53Api, "zoneId", "domain", current)
+
+	result, err := claimRecordChanges(context.TODO(), mockedRoute53Api, "zoneId", dnsSettings{Domain: "domain", Owner: "task/cluster"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []route53Types.Change{ownerChange("domain", recordOwner{Id: "task/cluster", OrphanSince: map[string]time.Time{"1.1.1.1": time.Unix(100, 0)}})}, result)
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_ClaimRecordChanges_SameOwner(t *testing.T) {
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	mockOwnerRecord(mockedRoute53Api, "zoneId", "domain", ownerRecordSet("domain", recordOwner{Id: "task/cluster"}))
+
+	result, err := claimRecordChanges(context.TODO(), mockedRoute53Api, "zoneId", dnsSettings{Domain: "domain", Owner: "task/cluster"})
+
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_ListOwnedRecords(t *testing.T) {
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	owned := route53Types.ResourceRecordSet{Name: aws.String("a.example.com."), Type: route53Types.RRTypeA}
+	ownerSet := *ownerRecordSet("a.example.com.", recordOwner{Id: "task/cluster"})
+	static := route53Types.ResourceRecordSet{Name: aws.String("static.example.com."), Type: route53Types.RRTypeA}
+
+	output := &route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{ownerSet, owned, static}}
+	mockedRoute53Api.On("ListResourceRecordSets", context.TODO(), &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String("zoneId")}).Return(output, nil).Once()
+
+	result, err := listOwnedRecords(context.TODO(), mockedRoute53Api, "zoneId")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []ownedRecord{{RecordSet: owned, OwnerSet: ownerSet, Owner: recordOwner{Id: "task/cluster", OrphanSince: map[string]time.Time{}}}}, result)
+	mockedRoute53Api.AssertExpectations(t)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	dnsNameTagKey     = "ecs-sidecar:dns-name"
+	zoneIdTagKey      = "ecs-sidecar:zone-id"
+	publicIpTagKey    = "ecs-sidecar:public-ip"
+	publishedAtTagKey = "ecs-sidecar:published-at"
+)
+
+// tagPublishedResources records on the task and on its eni which dns name
+// the sidecar published, so the owner of a record can be found from the AWS side.
+func tagPublishedResources(ctx context.Context, ecsApi EcsApi, ec2Api Ec2Api, taskArn string, taskEni string, settings dnsSettings, publicIp string, publishedAt time.Time) error {
+	values := map[string]string{
+		dnsNameTagKey:     settings.Domain,
+		zoneIdTagKey:      settings.HostedZoneId,
+		publicIpTagKey:    publicIp,
+		publishedAtTagKey: publishedAt.UTC().Format(time.RFC3339),
+	}
+	keys := []string{dnsNameTagKey, zoneIdTagKey, publicIpTagKey, publishedAtTagKey}
+
+	ecsTags := []ecsTypes.Tag{}
+	ec2Tags := []ec2Types.Tag{}
+	for _, key := range keys {
+		ecsTags = append(ecsTags, ecsTypes.Tag{Key: aws.String(key), Value: aws.String(values[key])})
+		ec2Tags = append(ec2Tags, ec2Types.Tag{Key: aws.String(key), Value: aws.String(values[key])})
+	}
+
+	tagResourceInput := &ecs.TagResourceInput{
+		ResourceArn: aws.String(taskArn),
+		Tags:        ecsTags,
+	}
+
+	_, err := ecsApi.TagResource(ctx, tagResourceInput)
+	if err != nil {
+		return fmt.Errorf("error tagging task with arn '%v': %v", taskArn, err)
+	}
+
+	createTagsInput := &ec2.CreateTagsInput{
+		Resources: []string{taskEni},
+		Tags:      ec2Tags,
+	}
+
+	_, err = ec2Api.CreateTags(ctx, createTagsInput)
+	if err != nil {
+		return fmt.Errorf("error tagging network interface with id '%v': %v", taskEni, err)
+	}
+
+	log.Printf("Tagged task '%v' and eni '%v' with dns name '%v'\n", taskArn, taskEni, settings.Domain)
+
+	return nil
+}
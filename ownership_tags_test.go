@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPublishedAt = time.Date(2022, 3, 1, 10, 30, 0, 0, time.UTC)
+
+func expectedTagResourceInput() *ecs.TagResourceInput {
+	return &ecs.TagResourceInput{
+		ResourceArn: aws.String("taskArn"),
+		Tags: []ecsTypes.Tag{
+			{Key: aws.String(dnsNameTagKey), Value: aws.String("domain")},
+			{Key: aws.String(zoneIdTagKey), Value: aws.String("zoneId")},
+			{Key: aws.String(publicIpTagKey), Value: aws.String("ip")},
+			{Key: aws.String(publishedAtTagKey), Value: aws.String("2022-03-01T10:30:00Z")},
+		},
+	}
+}
+
+func Test_TagPublishedResources_TagResource_Error(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedEc2Api := NewMockedEc2Api()
+
+	mockedEcsApi.On("TagResource", ctx, expectedTagResourceInput()).Return(nil, fmt.Errorf("some error"))
+
+	err := tagPublishedResources(ctx, mockedEcsApi, mockedEc2Api, "taskArn", "taskEni", dnsSettings{Domain: "domain", HostedZoneId: "zoneId"}, "ip", testPublishedAt)
+
+	assert.EqualError(t, err, "error tagging task with arn 'taskArn': some error")
+
+	mockedEcsApi.AssertExpectations(t)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_TagPublishedResources_CreateTags_Error(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedEc2Api := NewMockedEc2Api()
+
+	mockedEcsApi.On("TagResource", ctx, expectedTagResourceInput()).Return(&ecs.TagResourceOutput{}, nil)
+
+	createTagsInput := &ec2.CreateTagsInput{
+		Resources: []string{"taskEni"},
+		Tags: []ec2Types.Tag{
+			{Key: aws.String(dnsNameTagKey), Value: aws.String("domain")},
+			{Key: aws.String(zoneIdTagKey), Value: aws.String("zoneId")},
+			{Key: aws.String(publicIpTagKey), Value: aws.String("ip")},
+			{Key: aws.String(publishedAtTagKey), Value: aws.String("2022-03-01T10:30:00Z")},
+		},
+	}
+
+	mockedEc2Api.On("CreateTags", ctx, createTagsInput).Return(nil, fmt.Errorf("some error"))
+
+	err := tagPublishedResources(ctx, mockedEcsApi, mockedEc2Api, "taskArn", "taskEni", dnsSettings{Domain: "domain", HostedZoneId: "zoneId"}, "ip", testPublishedAt)
+
+	assert.EqualError(t, err, "error tagging network interface with id 'taskEni': some error")
+
+	mockedEcsApi.AssertExpectations(t)
+	mockedEc2Api.AssertExpectations(t)
+}
+
+func Test_TagPublishedResources_Ok(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedEc2Api := NewMockedEc2Api()
+
+	mockedEcsApi.On("TagResource", ctx, expectedTagResourceInput()).Return(&ecs.TagResourceOutput{}, nil)
+
+	createTagsInput := &ec2.CreateTagsInput{
+		Resources: []string{"taskEni"},
+		Tags: []ec2Types.Tag{
+			{Key: aws.String(dnsNameTagKey), Value: aws.String("domain")},
+			{Key: aws.String(zoneIdTagKey), Value: aws.String("zoneId")},
+			{Key: aws.String(publicIpTagKey), Value: aws.String("ip")},
+			{Key: aws.String(publishedAtTagKey), Value: aws.String("2022-03-01T10:30:00Z")},
+		},
+	}
+
+	mockedEc2Api.On("CreateTags", ctx, createTagsInput).Return(&ec2.CreateTagsOutput{}, nil)
+
+	err := tagPublishedResources(ctx, mockedEcsApi, mockedEc2Api, "taskArn", "taskEni", dnsSettings{Domain: "domain", HostedZoneId: "zoneId"}, "ip", testPublishedAt)
+
+	assert.Nil(t, err)
+
+	mockedEcsApi.AssertExpectations(t)
+	mockedEc2Api.AssertExpectations(t)
+}
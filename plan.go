@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	planActionCreate = "create"
+	planActionUpdate = "update"
+	planActionNoop   = "no-op"
+
+	planOutputText = "text"
+	planOutputJson = "json"
+
+	// planExitCodeChanges is returned by the dry run when the record would change,
+	// following the terraform plan -detailed-exitcode convention.
+	planExitCodeChanges = 2
+)
+
+// errPlanHasChanges makes the dry run exit with planExitCodeChanges.
+var errPlanHasChanges = errors.New("the plan has changes")
+
+type recordPlan struct {
+	Action       string   `json:"action"`
+	HostedZoneId string   `json:"hostedZoneId"`
+	Name         string   `json:"name"`
+	OldIps       []string `json:"oldIps,omitempty"`
+	NewIps       []string `json:"newIps"`
+	OldTTL       int64    `json:"oldTtl,omitempty"`
+	NewTTL       int64    `json:"newTtl"`
+}
+
+func (p recordPlan) hasChanges() bool {
+	return p.Action != planActionNoop
+}
+
+func planRoute53RecordSet(ctx context.Context, route53Api Route53Api, settings dnsSettings, publicIps []string) (recordPlan, error) {
+	current, err := getCurrentRecordSet(ctx, route53Api, settings)
+	if err != nil {
+		return recordPlan{}, err
+	}
+
+	if current == nil {
+		return newRecordPlan(settings, publicIps, false, nil, 0), nil
+	}
+
+	oldTTL := int64(0)
+	if current.TTL != nil {
+		oldTTL = *current.TTL
+	}
+
+	return newRecordPlan(settings, publicIps, true, recordSetIps(current), oldTTL), nil
+}
+
+// newRecordPlan compares the current record, if it exists, with the wanted one.
+func newRecordPlan(settings dnsSettings, publicIps []string, exists bool, oldIps []string, oldTTL int64) recordPlan {
+	plan := recordPlan{
+		HostedZoneId: settings.HostedZoneId,
+		Name:         settings.Domain,
+		NewIps:       publicIps,
+		NewTTL:       settings.TTL,
+	}
+
+	if !exists {
+		plan.Action = planActionCreate
+
+		return plan
+	}
+
+	plan.OldIps = oldIps
+	plan.OldTTL = oldTTL
+
+	if sameIps(plan.OldIps, plan.NewIps) && plan.OldTTL == plan.NewTTL {
+		plan.Action = planActionNoop
+	} else {
+		plan.Action = planActionUpdate
+	}
+
+	return plan
+}
+
+func printPlan(w io.Writer, plan recordPlan, format string) error {
+	switch format {
+	case planOutputJson:
+		return json.NewEncoder(w).Encode(plan)
+	case planOutputText:
+		var err error
+		switch plan.Action {
+		case planActionCreate:
+			_, err = fmt.Fprintf(w, "+ create %v A %v (ttl %v) in hosted zone %v\n", plan.Name, strings.Join(plan.NewIps, ","), plan.NewTTL, plan.HostedZoneId)
+		case planActionUpdate:
+			_, err = fmt.Fprintf(w, "~ update %v A %v -> %v (ttl %v -> %v) in hosted zone %v\n", plan.Name, strings.Join(plan.OldIps, ","), strings.Join(plan.NewIps, ","), plan.OldTTL, plan.NewTTL, plan.HostedZoneId)
+		default:
+			_, err = fmt.Fprintf(w, "= no changes for %v A %v (ttl %v) in hosted zone %v\n", plan.Name, strings.Join(plan.NewIps, ","), plan.NewTTL, plan.HostedZoneId)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown output format '%v'", format)
+	}
+}
+
+func sameIps(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+
+	return true
+}
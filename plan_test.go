@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func expectedListResourceRecordSetsInput() *route53.ListResourceRecordSetsInput {
+	return &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String("zoneId"),
+		StartRecordName: aws.String("domain"),
+		StartRecordType: route53Types.RRTypeA,
+		MaxItems:        aws.Int32(1),
+	}
+}
+
+func Test_PlanRoute53RecordSet_ListResourceRecordSets_Error(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(nil, fmt.Errorf("some error")).Once()
+
+	result, err := planRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", HostedZoneId: "zoneId", TTL: 300}, []string{"ip"})
+
+	assert.Equal(t, recordPlan{}, result)
+	assert.EqualError(t, err, "error listing record sets in Route53 hosted zone 'zoneId' with domain 'domain': some error")
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_PlanRoute53RecordSet_Create(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	output := &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets: []route53Types.ResourceRecordSet{
+			{Name: aws.String("other."), Type: route53Types.RRTypeA},
+		},
+	}
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(output, nil).Once()
+
+	result, err := planRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", HostedZoneId: "zoneId", TTL: 300}, []string{"ip"})
+
+	assert.Equal(t, recordPlan{Action: planActionCreate, HostedZoneId: "zoneId", Name: "domain", NewIps: []string{"ip"}, NewTTL: 300}, result)
+	assert.True(t, result.hasChanges())
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_PlanRoute53RecordSet_Update(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	output := &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets: []route53Types.ResourceRecordSet{
+			{
+				Name:            aws.String("domain."),
+				Type:            route53Types.RRTypeA,
+				TTL:             aws.Int64(300),
+				ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("oldIp")}},
+			},
+		},
+	}
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(output, nil).Once()
+
+	result, err := planRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", HostedZoneId: "zoneId", TTL: 300}, []string{"ip"})
+
+	assert.Equal(t, recordPlan{Action: planActionUpdate, HostedZoneId: "zoneId", Name: "domain", OldIps: []string{"oldIp"}, NewIps: []string{"ip"}, OldTTL: 300, NewTTL: 300}, result)
+	assert.True(t, result.hasChanges())
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_PlanRoute53RecordSet_Noop(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	output := &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets: []route53Types.ResourceRecordSet{
+			{
+				Name:            aws.String("DOMAIN."),
+				Type:            route53Types.RRTypeA,
+				TTL:             aws.Int64(300),
+				ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("ip")}},
+			},
+		},
+	}
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, expectedListResourceRecordSetsInput()).Return(output, nil).Once()
+
+	result, err := planRoute53RecordSet(ctx, mockedRoute53Api, dnsSettings{Domain: "domain", HostedZoneId: "zoneId", TTL: 300}, []string{"ip"})
+
+	assert.Equal(t, planActionNoop, result.Action)
+	assert.False(t, result.hasChanges())
+	assert.Nil(t, err)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_PrintPlan_Text(t *testing.T) {
+	var buf bytes.Buffer
+
+	plan := recordPlan{Action: planActionUpdate, HostedZoneId: "zoneId", Name: "domain", OldIps: []string{"oldIp"}, NewIps: []string{"ip"}, OldTTL: 60, NewTTL: 300}
+
+	err := printPlan(&buf, plan, planOutputText)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "~ update domain A oldIp -> ip (ttl 60 -> 300) in hosted zone zoneId\n", buf.String())
+}
+
+func Test_PrintPlan_Json(t *testing.T) {
+	var buf bytes.Buffer
+
+	plan := recordPlan{Action: planActionCreate, HostedZoneId: "zoneId", Name: "domain", NewIps: []string{"ip"}, NewTTL: 300}
+
+	err := printPlan(&buf, plan, planOutputJson)
+
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"action":"create","hostedZoneId":"zoneId","name":"domain","newIps":["ip"],"newTtl":300}`, buf.String())
+}
+
+func Test_PrintPlan_UnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := printPlan(&buf, recordPlan{}, "yaml")
+
+	assert.EqualError(t, err, "unknown output format 'yaml'")
+}
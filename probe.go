@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	probeSchemeTcp   = "tcp"
+	probeSchemeHttp  = "http"
+	probeSchemeHttps = "https"
+	probeSchemeGrpc  = "grpc"
+
+	defaultProbeTimeout    = 2 * time.Second
+	defaultProbeHttpStatus = http.StatusOK
+)
+
+// Probe checks that the app is ready to serve. Check returns nil when it is.
+type Probe interface {
+	Check(ctx context.Context) error
+	String() string
+}
+
+type tcpProbe struct {
+	address string
+}
+
+func (p *tcpProbe) Check(ctx context.Context) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+func (p *tcpProbe) String() string {
+	return probeSchemeTcp + "://" + p.address
+}
+
+type httpProbe struct {
+	url            string
+	expectedStatus int
+	client         *http.Client
+}
+
+func (p *httpProbe) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != p.expectedStatus {
+		return fmt.Errorf("expected status %v, got %v", p.expectedStatus, resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (p *httpProbe) String() string {
+	return p.url
+}
+
+// grpcProbe uses the standard gRPC health checking protocol. An empty service
+// checks the health of the whole server.
+type grpcProbe struct {
+	address string
+	service string
+}
+
+func (p *grpcProbe) Check(ctx context.Context) error {
+	conn, err := grpc.DialContext(ctx, p.address, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: p.service})
+	if err != nil {
+		return err
+	}
+
+	if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("status is %v", resp.Status)
+	}
+
+	return nil
+}
+
+func (p *grpcProbe) String() string {
+	return probeSchemeGrpc + "://" + p.address + "/" + p.service
+}
+
+// parseProbe builds a probe from an url like tcp://localhost:8080,
+// http://localhost:8080/ready or grpc://localhost:9090/service.
+func parseProbe(spec string, expectedStatus int) (Probe, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid probe '%v': %v", spec, err)
+	}
+
+	if len(u.Host) == 0 {
+		return nil, fmt.Errorf("invalid probe '%v': no host", spec)
+	}
+
+	switch u.Scheme {
+	case probeSchemeTcp:
+		return &tcpProbe{address: u.Host}, nil
+	case probeSchemeHttp, probeSchemeHttps:
+		return &httpProbe{url: spec, expectedStatus: expectedStatus, client: &http.Client{}}, nil
+	case probeSchemeGrpc:
+		return &grpcProbe{address: u.Host, service: strings.TrimPrefix(u.Path, "/")}, nil
+	}
+
+	return nil, fmt.Errorf("invalid probe '%v': unsupported scheme '%v'", spec, u.Scheme)
+}
+
+type probeResult struct {
+	Probe     string    `json:"probe"`
+	Ok        bool      `json:"ok"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// ProbeSet runs the probes and keeps the last result of each one.
+type ProbeSet struct {
+	probes  []Probe
+	timeout time.Duration
+	mu      sync.Mutex
+	results []probeResult
+	now     func() time.Time
+}
+
+func NewProbeSet(probes []Probe, timeout time.Duration) *ProbeSet {
+	return &ProbeSet{
+		probes:  probes,
+		timeout: timeout,
+		results: []probeResult{},
+		now:     time.Now,
+	}
+}
+
+// Check is healthy when every probe succeeds. Failed probes are not errors,
+// they only make the task unhealthy.
+func (s *ProbeSet) Check(ctx context.Context) (bool, error) {
+	healthy := true
+	results := []probeResult{}
+
+	for _, probe := range s.probes {
+		probeCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		err := probe.Check(probeCtx)
+		cancel()
+
+		result := probeResult{Probe: probe.String(), Ok: err == nil, CheckedAt: s.now()}
+		if err != nil {
+			result.Error = err.Error()
+			healthy = false
+
+			log.Printf("Probe '%v' failed: %v\n", probe, err)
+		} else {
+			log.Printf("Probe '%v' succeeded\n", probe)
+		}
+
+		results = append(results, result)
+	}
+
+	s.mu.Lock()
+	s.results = results
+	s.mu.Unlock()
+
+	return healthy, nil
+}
+
+func (s *ProbeSet) Results() []probeResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.results
+}
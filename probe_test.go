@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func Test_ParseProbe(t *testing.T) {
+	probe, err := parseProbe("tcp://localhost:8080", 200)
+	assert.Nil(t, err)
+	assert.Equal(t, &tcpProbe{address: "localhost:8080"}, probe)
+
+	probe, err = parseProbe("http://localhost:8080/ready", 204)
+	assert.Nil(t, err)
+	assert.Equal(t, &httpProbe{url: "http://localhost:8080/ready", expectedStatus: 204, client: &http.Client{}}, probe)
+
+	probe, err = parseProbe("grpc://localhost:9090/app", 200)
+	assert.Nil(t, err)
+	assert.Equal(t, &grpcProbe{address: "localhost:9090", service: "app"}, probe)
+
+	_, err = parseProbe("udp://localhost:53", 200)
+	assert.EqualError(t, err, "invalid probe 'udp://localhost:53': unsupported scheme 'udp'")
+
+	_, err = parseProbe("localhost:8080", 200)
+	assert.EqualError(t, err, "invalid probe 'localhost:8080': no host")
+}
+
+func Test_TcpProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	probe := &tcpProbe{address: listener.Addr().String()}
+
+	assert.Nil(t, probe.Check(context.TODO()))
+
+	listener.Close()
+
+	assert.NotNil(t, probe.Check(context.TODO()))
+}
+
+func Test_HttpProbe(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	probe, err := parseProbe(server.URL+"/ready", http.StatusOK)
+	assert.Nil(t, err)
+
+	assert.EqualError(t, probe.Check(context.TODO()), "expected status 200, got 503")
+
+	status = http.StatusOK
+
+	assert.Nil(t, probe.Check(context.TODO()))
+}
+
+func Test_GrpcProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("app", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	server := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	probe := &grpcProbe{address: listener.Addr().String(), service: "app"}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	assert.EqualError(t, probe.Check(ctx), "status is NOT_SERVING")
+
+	healthServer.SetServingStatus("app", grpc_health_v1.HealthCheckResponse_SERVING)
+
+	assert.Nil(t, probe.Check(ctx))
+}
+
+func Test_ProbeSet_Check(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	now := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	probeSet := NewProbeSet([]Probe{&tcpProbe{address: listener.Addr().String()}, &httpProbe{url: "http://127.0.0.1:0/ready", expectedStatus: 200, client: &http.Client{}}}, time.Second)
+	probeSet.now = func() time.Time {
+		return now
+	}
+
+	result, err := probeSet.Check(context.TODO())
+
+	assert.False(t, result)
+	assert.Nil(t, err)
+
+	results := probeSet.Results()
+
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, probeResult{Probe: "tcp://" + listener.Addr().String(), Ok: true, CheckedAt: now}, results[0])
+	assert.Equal(t, "http://127.0.0.1:0/ready", results[1].Probe)
+	assert.False(t, results[1].Ok)
+	assert.NotEmpty(t, results[1].Error)
+}
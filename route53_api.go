@@ -11,6 +11,7 @@ import (
 type Route53Api interface {
 	ListHostedZones(ctx context.Context, params *route53.ListHostedZonesInput) (*route53.ListHostedZonesOutput, error)
 	ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error)
+	ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error)
 }
 
 type AwsRoute53Api struct {
@@ -18,7 +19,11 @@ type AwsRoute53Api struct {
 }
 
 func NewAwsRoute53Api(cfg aws.Config) *AwsRoute53Api {
-	route53Client := route53.NewFromConfig(cfg)
+	// RetryingRoute53Api retries the requests, stacking the sdk retries below
+	// it would multiply the attempts
+	route53Client := route53.NewFromConfig(cfg, func(o *route53.Options) {
+		o.Retryer = aws.NopRetryer{}
+	})
 
 	return &AwsRoute53Api{route53Client: route53Client}
 }
@@ -31,6 +36,10 @@ func (a *AwsRoute53Api) ChangeResourceRecordSets(ctx context.Context, params *ro
 	return a.route53Client.ChangeResourceRecordSets(ctx, params)
 }
 
+func (a *AwsRoute53Api) ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	return a.route53Client.ListResourceRecordSets(ctx, params)
+}
+
 type MockedRoute53Api struct {
 	mock.Mock
 }
@@ -58,3 +67,13 @@ func (m *MockedRoute53Api) ChangeResourceRecordSets(ctx context.Context, params
 
 	return args.Get(0).(*route53.ChangeResourceRecordSetsOutput), args.Error(1)
 }
+
+func (m *MockedRoute53Api) ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*route53.ListResourceRecordSetsOutput), args.Error(1)
+}
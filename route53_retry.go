@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	throttlingErrorCode              = "Throttling"
+	priorRequestNotCompleteErrorCode = "PriorRequestNotComplete"
+
+	// Route53 allows 5 requests per second per account.
+	route53RequestsPerSecond = 5
+	route53RequestsBurst     = 5
+)
+
+type retryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// PriorRequestDelay is the minimum wait after a PriorRequestNotComplete error,
+	// because the previous change for the same record needs time to propagate.
+	PriorRequestDelay time.Duration
+	Deadline          time.Duration
+}
+
+func defaultRoute53RetryPolicy() retryPolicy {
+	return retryPolicy{
+		MaxAttempts:       10,
+		BaseDelay:         200 * time.Millisecond,
+		MaxDelay:          10 * time.Second,
+		PriorRequestDelay: 2 * time.Second,
+		Deadline:          2 * time.Minute,
+	}
+}
+
+// RetryingRoute53Api wraps a Route53Api limiting the request rate and retrying
+// throttled and transient errors with jittered exponential backoff. It is the
+// only retry layer, the Route53 client is built without the sdk retryer.
+type RetryingRoute53Api struct {
+	api     Route53Api
+	policy  retryPolicy
+	limiter *tokenBucket
+	sleep   func(ctx context.Context, d time.Duration) error
+	jitter  func(n int64) int64
+}
+
+func NewRetryingRoute53Api(api Route53Api, policy retryPolicy, limiter *tokenBucket) *RetryingRoute53Api {
+	return &RetryingRoute53Api{
+		api:     api,
+		policy:  policy,
+		limiter: limiter,
+		sleep:   sleepContext,
+		jitter:  rand.Int63n,
+	}
+}
+
+func (a *RetryingRoute53Api) ListHostedZones(ctx context.Context, params *route53.ListHostedZonesInput) (*route53.ListHostedZonesOutput, error) {
+	var output *route53.ListHostedZonesOutput
+
+	err := a.do(ctx, "ListHostedZones", func(ctx context.Context) error {
+		var err error
+		output, err = a.api.ListHostedZones(ctx, params)
+		return err
+	})
+
+	return output, err
+}
+
+func (a *RetryingRoute53Api) ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	var output *route53.ChangeResourceRecordSetsOutput
+
+	err := a.do(ctx, "ChangeResourceRecordSets", func(ctx context.Context) error {
+		var err error
+		output, err = a.api.ChangeResourceRecordSets(ctx, params)
+		return err
+	})
+
+	return output, err
+}
+
+func (a *RetryingRoute53Api) ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	var output *route53.ListResourceRecordSetsOutput
+
+	err := a.do(ctx, "ListResourceRecordSets", func(ctx context.Context) error {
+		var err error
+		output, err = a.api.ListResourceRecordSets(ctx, params)
+		return err
+	})
+
+	return output, err
+}
+
+func (a *RetryingRoute53Api) do(ctx context.Context, operation string, call func(ctx context.Context) error) error {
+	if a.policy.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.policy.Deadline)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		if a.limiter != nil {
+			if err := a.limiter.Wait(ctx); err != nil {
+				return fmt.Errorf("error waiting for the Route53 rate limiter: %v", err)
+			}
+		}
+
+		err := call(ctx)
+		if err == nil {
+			return nil
+		}
+
+		code := awsErrorCode(err)
+		if !isRetryableRoute53Error(err) {
+			return err
+		}
+
+		if attempt >= a.policy.MaxAttempts {
+			return fmt.Errorf("giving up %v after %v attempts: %w", operation, attempt, err)
+		}
+
+		delay := a.backoff(attempt, code)
+
+		log.Printf("%v failed with '%v' (attempt %v), retrying in %v\n", operation, err, attempt, delay)
+
+		if err := a.sleep(ctx, delay); err != nil {
+			return fmt.Errorf("giving up %v after %v attempts, deadline exceeded: %w", operation, attempt, err)
+		}
+	}
+}
+
+// backoff returns a full jitter exponential delay. PriorRequestNotComplete
+// always waits at least PriorRequestDelay on top of the jitter.
+func (a *RetryingRoute53Api) backoff(attempt int, code string) time.Duration {
+	ceiling := a.policy.BaseDelay << uint(attempt-1)
+	if ceiling <= 0 || ceiling > a.policy.MaxDelay {
+		ceiling = a.policy.MaxDelay
+	}
+
+	delay := time.Duration(a.jitter(int64(ceiling) + 1))
+
+	if code == priorRequestNotCompleteErrorCode {
+		delay += a.policy.PriorRequestDelay
+	}
+
+	return delay
+}
+
+func awsErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+
+	return ""
+}
+
+// isRetryableRoute53Error also retries the connection errors and 5xx
+// responses the sdk retryer would have retried.
+func isRetryableRoute53Error(err error) bool {
+	code := awsErrorCode(err)
+	if code == throttlingErrorCode || code == priorRequestNotCompleteErrorCode {
+		return true
+	}
+
+	return retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// tokenBucket is a client side rate limiter shared by every Route53 call made by the process.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+func newTokenBucket(ratePerSecond float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   ratePerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  sleepContext,
+	}
+}
+
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+
+		now := b.now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+
+			return nil
+		}
+
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+
+		b.mu.Unlock()
+
+		if err := b.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
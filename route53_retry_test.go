@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestRetryingRoute53Api(api Route53Api, policy retryPolicy) (*RetryingRoute53Api, *[]time.Duration) {
+	delays := []time.Duration{}
+
+	retryingApi := NewRetryingRoute53Api(api, policy, nil)
+	retryingApi.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	retryingApi.jitter = func(n int64) int64 {
+		return n - 1
+	}
+
+	return retryingApi, &delays
+}
+
+func testRetryPolicy() retryPolicy {
+	return retryPolicy{
+		MaxAttempts:       4,
+		BaseDelay:         100 * time.Millisecond,
+		MaxDelay:          300 * time.Millisecond,
+		PriorRequestDelay: time.Second,
+	}
+}
+
+func throttlingError() error {
+	return &smithy.GenericAPIError{Code: throttlingErrorCode, Message: "Rate exceeded"}
+}
+
+func Test_RetryingRoute53Api_ChangeResourceRecordSets_NotRetryableError(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	retryingApi, delays := newTestRetryingRoute53Api(mockedRoute53Api, testRetryPolicy())
+
+	input := &route53.ChangeResourceRecordSetsInput{}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", mock.Anything, input).Return(nil, fmt.Errorf("some error")).Once()
+
+	result, err := retryingApi.ChangeResourceRecordSets(ctx, input)
+
+	assert.Nil(t, result)
+	assert.EqualError(t, err, "some error")
+	assert.Empty(t, *delays)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_RetryingRoute53Api_ChangeResourceRecordSets_ThrottledThenOk(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	retryingApi, delays := newTestRetryingRoute53Api(mockedRoute53Api, testRetryPolicy())
+
+	input := &route53.ChangeResourceRecordSetsInput{}
+	output := &route53.ChangeResourceRecordSetsOutput{}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", mock.Anything, input).Return(nil, throttlingError()).Times(3)
+	mockedRoute53Api.On("ChangeResourceRecordSets", mock.Anything, input).Return(output, nil).Once()
+
+	result, err := retryingApi.ChangeResourceRecordSets(ctx, input)
+
+	assert.Equal(t, output, result)
+	assert.Nil(t, err)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}, *delays)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_RetryingRoute53Api_ChangeResourceRecordSets_PriorRequestNotComplete(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	retryingApi, delays := newTestRetryingRoute53Api(mockedRoute53Api, testRetryPolicy())
+
+	input := &route53.ChangeResourceRecordSetsInput{}
+	output := &route53.ChangeResourceRecordSetsOutput{}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", mock.Anything, input).Return(nil, &route53Types.PriorRequestNotComplete{}).Once()
+	mockedRoute53Api.On("ChangeResourceRecordSets", mock.Anything, input).Return(output, nil).Once()
+
+	result, err := retryingApi.ChangeResourceRecordSets(ctx, input)
+
+	assert.Equal(t, output, result)
+	assert.Nil(t, err)
+	assert.Equal(t, []time.Duration{1100 * time.Millisecond}, *delays)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_RetryingRoute53Api_ChangeResourceRecordSets_MaxAttemptsExceeded(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	retryingApi, delays := newTestRetryingRoute53Api(mockedRoute53Api, testRetryPolicy())
+
+	input := &route53.ChangeResourceRecordSetsInput{}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", mock.Anything, input).Return(nil, throttlingError()).Times(4)
+
+	result, err := retryingApi.ChangeResourceRecordSets(ctx, input)
+
+	assert.Nil(t, result)
+	assert.EqualError(t, err, "giving up ChangeResourceRecordSets after 4 attempts: api error Throttling: Rate exceeded")
+	assert.Len(t, *delays, 3)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_RetryingRoute53Api_ListHostedZones_DeadlineExceeded(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	retryingApi, _ := newTestRetryingRoute53Api(mockedRoute53Api, testRetryPolicy())
+	retryingApi.sleep = func(ctx context.Context, d time.Duration) error {
+		return context.DeadlineExceeded
+	}
+
+	input := &route53.ListHostedZonesInput{}
+
+	mockedRoute53Api.On("ListHostedZones", mock.Anything, input).Return(nil, throttlingError()).Once()
+
+	result, err := retryingApi.ListHostedZones(ctx, input)
+
+	assert.Nil(t, result)
+	assert.EqualError(t, err, "giving up ListHostedZones after 1 attempts, deadline exceeded: context deadline exceeded")
+
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_TokenBucket_Wait(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	delays := []time.Duration{}
+
+	bucket := newTokenBucket(2, 2)
+	bucket.last = now
+	bucket.now = func() time.Time {
+		return now
+	}
+	bucket.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		now = now.Add(d)
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, bucket.Wait(ctx))
+	}
+
+	assert.Equal(t, []time.Duration{500 * time.Millisecond}, delays)
+}
+
+func Test_TokenBucket_Wait_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	bucket := newTokenBucket(1, 1)
+	bucket.tokens = 0
+
+	err := bucket.Wait(ctx)
+
+	assert.Equal(t, context.Canceled, err)
+}
+
+func Test_RetryingRoute53Api_ChangeResourceRecordSets_ServerErrorThenOk(t *testing.T) {
+	ctx := context.TODO()
+	mockedRoute53Api := NewMockedRoute53Api()
+	retryingApi, delays := newTestRetryingRoute53Api(mockedRoute53Api, testRetryPolicy())
+
+	input := &route53.ChangeResourceRecordSetsInput{}
+	output := &route53.ChangeResourceRecordSetsOutput{}
+	serverError := &smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}, Err: fmt.Errorf("service unavailable")}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", mock.Anything, input).Return(nil, serverError).Once()
+	mockedRoute53Api.On("ChangeResourceRecordSets", mock.Anything, input).Return(output, nil).Once()
+
+	result, err := retryingApi.ChangeResourceRecordSets(ctx, input)
+
+	assert.Equal(t, output, result)
+	assert.Nil(t, err)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond}, *delays)
+
+	mockedRoute53Api.AssertExpectations(t)
+}
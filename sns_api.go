@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/stretchr/testify/mock"
+)
+
+type SnsApi interface {
+	Publish(ctx context.Context, params *sns.PublishInput) (*sns.PublishOutput, error)
+}
+
+type AwsSnsApi struct {
+	snsClient *sns.Client
+}
+
+func NewAwsSnsApi(cfg aws.Config) *AwsSnsApi {
+	snsClient := sns.NewFromConfig(cfg)
+
+	return &AwsSnsApi{snsClient: snsClient}
+}
+
+func (a *AwsSnsApi) Publish(ctx context.Context, params *sns.PublishInput) (*sns.PublishOutput, error) {
+	return a.snsClient.Publish(ctx, params)
+}
+
+type MockedSnsApi struct {
+	mock.Mock
+}
+
+func NewMockedSnsApi() *MockedSnsApi {
+	return &MockedSnsApi{}
+}
+
+func (m *MockedSnsApi) Publish(ctx context.Context, params *sns.PublishInput) (*sns.PublishOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*sns.PublishOutput), args.Error(1)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/mock"
+)
+
+type SqsApi interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error)
+}
+
+type AwsSqsApi struct {
+	sqsClient *sqs.Client
+}
+
+func NewAwsSqsApi(cfg aws.Config) *AwsSqsApi {
+	sqsClient := sqs.NewFromConfig(cfg)
+
+	return &AwsSqsApi{sqsClient: sqsClient}
+}
+
+func (a *AwsSqsApi) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	return a.sqsClient.ReceiveMessage(ctx, params)
+}
+
+func (a *AwsSqsApi) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	return a.sqsClient.DeleteMessage(ctx, params)
+}
+
+type MockedSqsApi struct {
+	mock.Mock
+}
+
+func NewMockedSqsApi() *MockedSqsApi {
+	return &MockedSqsApi{}
+}
+
+func (m *MockedSqsApi) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*sqs.ReceiveMessageOutput), args.Error(1)
+}
+
+func (m *MockedSqsApi) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	args := m.Called(ctx, params)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*sqs.DeleteMessageOutput), args.Error(1)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	sqsCommand = "sqs"
+
+	sqsMaxNumberOfMessages = 10
+	sqsWaitTimeSeconds     = 20
+
+	sqsErrorDelay = 5 * time.Second
+)
+
+// SqsConsumer long-polls a queue fed by the EventBridge ECS task state change rule.
+type SqsConsumer struct {
+	sqsApi   SqsApi
+	queueUrl string
+	handler  *TaskEventHandler
+}
+
+func NewSqsConsumer(sqsApi SqsApi, queueUrl string, handler *TaskEventHandler) *SqsConsumer {
+	return &SqsConsumer{
+		sqsApi:   sqsApi,
+		queueUrl: queueUrl,
+		handler:  handler,
+	}
+}
+
+func runSqsConsumer(ctx context.Context, cfg aws.Config, clusterName string, useServiceTags bool, defaults dnsSettings, args []string) {
+	flags := flag.NewFlagSet(sqsCommand, flag.ExitOnError)
+	queueUrl := flags.String("queue-url", os.Getenv("QUEUE_URL"), "url of the queue receiving the ECS task state change events")
+	flags.Parse(args)
+
+	if len(*queueUrl) == 0 {
+		log.Fatal("no queue url configured")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ecsApi := InitEcsApi(cfg)
+	ec2Api := InitEc2Api(cfg)
+	route53Api := NewRetryingRoute53Api(InitRoute53Api(cfg), defaultRoute53RetryPolicy(), newTokenBucket(route53RequestsPerSecond, route53RequestsBurst))
+
+	handler := NewTaskEventHandler(ecsApi, ec2Api, route53Api, clusterName, useServiceTags, defaults)
+
+	NewSqsConsumer(InitSqsApi(cfg), *queueUrl, handler).Run(ctx)
+}
+
+func (c *SqsConsumer) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := c.Poll(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error polling queue '%v': %v\n", c.queueUrl, err)
+
+			sleepContext(ctx, sqsErrorDelay)
+		}
+	}
+}
+
+// Poll receives one batch of messages. Messages are deleted once handled, or
+// when they can't be parsed; messages that fail are left for SQS to redeliver.
+func (c *SqsConsumer) Poll(ctx context.Context) error {
+	receiveMessageInput := &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(c.queueUrl),
+		MaxNumberOfMessages: sqsMaxNumberOfMessages,
+		WaitTimeSeconds:     sqsWaitTimeSeconds,
+	}
+
+	receiveMessageOutput, err := c.sqsApi.ReceiveMessage(ctx, receiveMessageInput)
+	if err != nil {
+		return fmt.Errorf("error receiving messages: %v", err)
+	}
+
+	for _, message := range receiveMessageOutput.Messages {
+		err = c.handleMessage(ctx, message)
+		if err != nil {
+			log.Printf("Error handling message '%v': %v\n", aws.ToString(message.MessageId), err)
+			continue
+		}
+
+		deleteMessageInput := &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(c.queueUrl),
+			ReceiptHandle: message.ReceiptHandle,
+		}
+
+		_, err = c.sqsApi.DeleteMessage(ctx, deleteMessageInput)
+		if err != nil {
+			log.Printf("Error deleting message '%v': %v\n", aws.ToString(message.MessageId), err)
+		}
+	}
+
+	return nil
+}
+
+func (c *SqsConsumer) handleMessage(ctx context.Context, message sqsTypes.Message) error {
+	event := taskStateChangeEvent{}
+
+	err := json.Unmarshal([]byte(aws.ToString(message.Body)), &event)
+	if err != nil {
+		log.Printf("Discarding message '%v', it is not a valid event: %v\n", aws.ToString(message.MessageId), err)
+
+		return nil
+	}
+
+	_, err = c.handler.Handle(ctx, event)
+
+	return err
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const fixtureTaskArn = "arn:aws:ecs:eu-west-1:111122223333:task/cluster/a1b2c3d4"
+
+func readFixture(t *testing.T, name string) string {
+	content, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func expectedReceiveMessageInput() *sqs.ReceiveMessageInput {
+	return &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String("queueUrl"),
+		MaxNumberOfMessages: sqsMaxNumberOfMessages,
+		WaitTimeSeconds:     sqsWaitTimeSeconds,
+	}
+}
+
+func expectedFixtureDescribeTasksInput() *ecs.DescribeTasksInput {
+	return &ecs.DescribeTasksInput{
+		Cluster: aws.String("cluster"),
+		Tasks:   []string{fixtureTaskArn},
+		Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags},
+	}
+}
+
+func fixtureListResourceRecordSetsInput() *route53.ListResourceRecordSetsInput {
+	return &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String("zoneId"),
+		StartRecordName: aws.String("app.example.com"),
+		StartRecordType: route53Types.RRTypeA,
+		MaxItems:        aws.Int32(1),
+	}
+}
+
+func Test_SqsConsumer_Poll_ReceiveMessage_Error(t *testing.T) {
+	ctx := context.TODO()
+	mockedSqsApi := NewMockedSqsApi()
+
+	mockedSqsApi.On("ReceiveMessage", ctx, expectedReceiveMessageInput()).Return(nil, fmt.Errorf("some error"))
+
+	consumer := NewSqsConsumer(mockedSqsApi, "queueUrl", nil)
+
+	err := consumer.Poll(ctx)
+
+	assert.EqualError(t, err, "error receiving messages: some error")
+
+	mockedSqsApi.AssertExpectations(t)
+}
+
+func Test_SqsConsumer_Poll_InvalidMessage_IsDeleted(t *testing.T) {
+	ctx := context.TODO()
+	mockedSqsApi := NewMockedSqsApi()
+
+	receiveMessageOutput := &sqs.ReceiveMessageOutput{
+		Messages: []sqsTypes.Message{
+			{MessageId: aws.String("id"), ReceiptHandle: aws.String("receiptHandle"), Body: aws.String("not json")},
+		},
+	}
+
+	mockedSqsApi.On("ReceiveMessage", ctx, expectedReceiveMessageInput()).Return(receiveMessageOutput, nil)
+	mockedSqsApi.On("DeleteMessage", ctx, &sqs.DeleteMessageInput{QueueUrl: aws.String("queueUrl"), ReceiptHandle: aws.String("receiptHandle")}).Return(&sqs.DeleteMessageOutput{}, nil)
+
+	consumer := NewSqsConsumer(mockedSqsApi, "queueUrl", nil)
+
+	err := consumer.Poll(ctx)
+
+	assert.Nil(t, err)
+
+	mockedSqsApi.AssertExpectations(t)
+}
+
+func Test_SqsConsumer_Poll_HandlerError_IsNotDeleted(t *testing.T) {
+	ctx := context.TODO()
+	mockedSqsApi := NewMockedSqsApi()
+	mockedEcsApi := NewMockedEcsApi()
+
+	receiveMessageOutput := &sqs.ReceiveMessageOutput{
+		Messages: []sqsTypes.Message{
+			{MessageId: aws.String("id"), ReceiptHandle: aws.String("receiptHandle"), Body: aws.String(readFixture(t, "task_state_change_running.json"))},
+		},
+	}
+
+	mockedSqsApi.On("ReceiveMessage", ctx, expectedReceiveMessageInput()).Return(receiveMessageOutput, nil)
+	mockedEcsApi.On("DescribeTasks", ctx, expectedFixtureDescribeTasksInput()).Return(nil, fmt.Errorf("some error"))
+
+	handler := NewTaskEventHandler(mockedEcsApi, NewMockedEc2Api(), NewMockedRoute53Api(), "cluster", false, dnsSettings{TTL: 300})
+	consumer := NewSqsConsumer(mockedSqsApi, "queueUrl", handler)
+
+	err := consumer.Poll(ctx)
+
+	assert.Nil(t, err)
+
+	mockedSqsApi.AssertExpectations(t)
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func Test_SqsConsumer_Poll_TaskRunning_AddsIpToRecord(t *testing.T) {
+	ctx := context.TODO()
+	mockedSqsApi := NewMockedSqsApi()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedEc2Api := NewMockedEc2Api()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	receiveMessageOutput := &sqs.ReceiveMessageOutput{
+		Messages: []sqsTypes.Message{
+			{MessageId: aws.String("id"), ReceiptHandle: aws.String("receiptHandle"), Body: aws.String(readFixture(t, "task_state_change_running.json"))},
+		},
+	}
+
+	mockedSqsApi.On("ReceiveMessage", ctx, expectedReceiveMessageInput()).Return(receiveMessageOutput, nil)
+
+	task := taskWithEni(fixtureTaskArn, "eni-1234")
+	task.Tags = []ecsTypes.Tag{
+		{Key: aws.String(domainTagKey), Value: aws.String("app.example.com")},
+		{Key: aws.String(hostedZoneIdTagKey), Value: aws.String("zoneId")},
+	}
+
+	mockedEcsApi.On("DescribeTasks", ctx, expectedFixtureDescribeTasksInput()).Return(&ecs.DescribeTasksOutput{Tasks: []ecsTypes.Task{task}}, nil)
+
+	describeNetworkInterfacesOutput := &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []ec2Types.NetworkInterface{networkInterfaceWithIp("eni-1234", "1.1.1.1")},
+	}
+
+	mockedEc2Api.On("DescribeNetworkInterfaces", ctx, &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []string{"eni-1234"}}).Return(describeNetworkInterfacesOutput, nil)
+
+	currentRecordSet := route53Types.ResourceRecordSet{
+		Name:            aws.String("app.example.com."),
+		Type:            route53Types.RRTypeA,
+		TTL:             aws.Int64(300),
+		ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("2.2.2.2")}},
+	}
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, fixtureListResourceRecordSetsInput()).Return(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{currentRecordSet}}, nil)
+	mockOwnerRecord(mockedRoute53Api, "zoneId", "app.example.com", nil)
+
+	changeInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{
+					Action:            route53Types.ChangeActionDelete,
+					ResourceRecordSet: &currentRecordSet,
+				},
+				{
+					Action: route53Types.ChangeActionCreate,
+					ResourceRecordSet: &route53Types.ResourceRecordSet{
+						Type: route53Types.RRTypeA,
+						Name: aws.String("app.example.com"),
+						TTL:  aws.Int64(300),
+						ResourceRecords: []route53Types.ResourceRecord{
+							{Value: aws.String("2.2.2.2")},
+							{Value: aws.String("1.1.1.1")},
+						},
+					},
+				},
+				ownerChange("app.example.com", recordOwner{Id: "task/cluster"}),
+			},
+		},
+		HostedZoneId: aws.String("zoneId"),
+	}
+	changeOutput := &route53.ChangeResourceRecordSetsOutput{
+		ChangeInfo: &route53Types.ChangeInfo{Status: route53Types.ChangeStatusPending},
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, changeInput).Return(changeOutput, nil)
+	mockedEcsApi.On("TagResource", ctx, mock.AnythingOfType("*ecs.TagResourceInput")).Return(&ecs.TagResourceOutput{}, nil)
+	mockedEc2Api.On("CreateTags", ctx, mock.AnythingOfType("*ec2.CreateTagsInput")).Return(&ec2.CreateTagsOutput{}, nil)
+	mockedSqsApi.On("DeleteMessage", ctx, &sqs.DeleteMessageInput{QueueUrl: aws.String("queueUrl"), ReceiptHandle: aws.String("receiptHandle")}).Return(&sqs.DeleteMessageOutput{}, nil)
+
+	handler := NewTaskEventHandler(mockedEcsApi, mockedEc2Api, mockedRoute53Api, "cluster", false, dnsSettings{TTL: 300})
+	consumer := NewSqsConsumer(mockedSqsApi, "queueUrl", handler)
+
+	err := consumer.Poll(ctx)
+
+	assert.Nil(t, err)
+
+	mockedSqsApi.AssertExpectations(t)
+	mockedEcsApi.AssertExpectations(t)
+	mockedEc2Api.AssertExpectations(t)
+	mockedRoute53Api.AssertExpectations(t)
+}
+
+func Test_SqsConsumer_Poll_TaskStopped_DeletesRecord(t *testing.T) {
+	ctx := context.TODO()
+	mockedSqsApi := NewMockedSqsApi()
+	mockedEcsApi := NewMockedEcsApi()
+	mockedRoute53Api := NewMockedRoute53Api()
+
+	receiveMessageOutput := &sqs.ReceiveMessageOutput{
+		Messages: []sqsTypes.Message{
+			{MessageId: aws.String("id"), ReceiptHandle: aws.String("receiptHandle"), Body: aws.String(readFixture(t, "task_state_change_stopped.json"))},
+		},
+	}
+
+	mockedSqsApi.On("ReceiveMessage", ctx, expectedReceiveMessageInput()).Return(receiveMessageOutput, nil)
+
+	task := ecsTypes.Task{
+		TaskArn: aws.String(fixtureTaskArn),
+		Tags: []ecsTypes.Tag{
+			{Key: aws.String(dnsNameTagKey), Value: aws.String("app.example.com")},
+			{Key: aws.String(zoneIdTagKey), Value: aws.String("zoneId")},
+			{Key: aws.String(publicIpTagKey), Value: aws.String("1.1.1.1")},
+		},
+	}
+
+	mockedEcsApi.On("DescribeTasks", ctx, expectedFixtureDescribeTasksInput()).Return(&ecs.DescribeTasksOutput{Tasks: []ecsTypes.Task{task}}, nil)
+
+	currentRecordSet := route53Types.ResourceRecordSet{
+		Name:            aws.String("app.example.com."),
+		Type:            route53Types.RRTypeA,
+		TTL:             aws.Int64(60),
+		ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("1.1.1.1")}},
+	}
+
+	mockedRoute53Api.On("ListResourceRecordSets", ctx, fixtureListResourceRecordSetsInput()).Return(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: []route53Types.ResourceRecordSet{currentRecordSet}}, nil)
+	mockOwnerRecord(mockedRoute53Api, "zoneId", "app.example.com.", nil)
+
+	deleteInput := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53Types.ChangeBatch{
+			Changes: []route53Types.Change{
+				{
+					Action:            route53Types.ChangeActionDelete,
+					ResourceRecordSet: &currentRecordSet,
+				},
+			},
+		},
+		HostedZoneId: aws.String("zoneId"),
+	}
+
+	mockedRoute53Api.On("ChangeResourceRecordSets", ctx, deleteInput).Return(&route53.ChangeResourceRecordSetsOutput{}, nil)
+	mockedSqsApi.On("DeleteMessage", ctx, &sqs.DeleteMessageInput{QueueUrl: aws.String("queueUrl"), ReceiptHandle: aws.String("receiptHandle")}).Return(&sqs.DeleteMessageOutput{}, nil)
+
+	handler := NewTaskEventHandler(mockedEcsApi, NewMockedEc2Api(), mockedRoute53Api, "cluster", false, dnsSettings{TTL: 300})
+	consumer := NewSqsConsumer(mockedSqsApi, "queueUrl", handler)
+
+	err := consumer.Poll(ctx)
+
+	assert.Nil(t, err)
+
+	mockedSqsApi.AssertExpectations(t)
+	mockedEcsApi.AssertExpectations(t)
+	mockedRoute53Api.AssertExpectations(t)
+}
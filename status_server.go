@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	statusServerShutdownTimeout = 5 * time.Second
+
+	// unhealthySyncFailures is how many syncs in a row must fail before
+	// /healthz fails, so a transient Route53 error doesn't get the task killed
+	unhealthySyncFailures = 3
+)
+
+type publishedRecord struct {
+	Name         string `json:"name"`
+	HostedZoneId string `json:"hostedZoneId"`
+	TTL          int64  `json:"ttl"`
+	Published    bool   `json:"published"`
+}
+
+type sidecarStatus struct {
+	TaskArn             string            `json:"taskArn"`
+	Eni                 string            `json:"eni"`
+	PublicIps           []string          `json:"publicIps"`
+	EgressIp            string            `json:"egressIp,omitempty"`
+	Records             []publishedRecord `json:"records"`
+	LastSync            *time.Time        `json:"lastSync,omitempty"`
+	LastError           string            `json:"lastError,omitempty"`
+	ConsecutiveFailures int               `json:"consecutiveFailures,omitempty"`
+	Probes              []probeResult     `json:"probes,omitempty"`
+}
+
+// StatusServer keeps the status of the sidecar and exposes it, along with
+// sync and withdraw actions and the metrics, to the other containers of the task.
+type StatusServer struct {
+	syncMu   sync.Mutex
+	mu       sync.Mutex
+	status   sidecarStatus
+	probeSet *ProbeSet
+	gatherer prometheus.Gatherer
+	publish  func(ctx context.Context) error
+	withdraw func(ctx context.Context) error
+	isLeader func() bool
+	now      func() time.Time
+}
+
+func NewStatusServer(status sidecarStatus, probeSet *ProbeSet, gatherer prometheus.Gatherer, publish func(ctx context.Context) error, withdraw func(ctx context.Context) error) *StatusServer {
+	return &StatusServer{
+		status:   status,
+		probeSet: probeSet,
+		gatherer: gatherer,
+		publish:  publish,
+		withdraw: withdraw,
+		now:      time.Now,
+	}
+}
+
+// RequireLeader makes /sync and /withdraw fail with 409 while isLeader returns
+// false, so only the leader changes a record shared through leader election.
+func (s *StatusServer) RequireLeader(isLeader func() bool) {
+	s.isLeader = isLeader
+}
+
+// Publish publishes the records, recording the result in the status.
+func (s *StatusServer) Publish(ctx context.Context) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	err := s.publish(ctx)
+	s.recordSync(true, err)
+
+	return err
+}
+
+// Withdraw removes the ip of the task from the records, recording the result
+// in the status.
+func (s *StatusServer) Withdraw(ctx context.Context) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	err := s.withdraw(ctx)
+	s.recordSync(false, err)
+
+	return err
+}
+
+func (s *StatusServer) recordSync(published bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.status.LastSync = &now
+	s.status.LastError = ""
+
+	if err != nil {
+		s.status.LastError = err.Error()
+		s.status.ConsecutiveFailures++
+		return
+	}
+
+	s.status.ConsecutiveFailures = 0
+
+	for i := range s.status.Records {
+		s.status.Records[i].Published = published
+	}
+}
+
+func (s *StatusServer) Status() sidecarStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status
+	status.Records = append([]publishedRecord{}, s.status.Records...)
+
+	if s.probeSet != nil {
+		status.Probes = s.probeSet.Results()
+	}
+
+	return status
+}
+
+func (s *StatusServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/sync", s.handleAction(s.Publish))
+	mux.HandleFunc("/withdraw", s.handleAction(s.Withdraw))
+
+	if s.gatherer != nil {
+		mux.Handle("/metrics", promhttp.HandlerFor(s.gatherer, promhttp.HandlerOpts{}))
+	}
+
+	return mux
+}
+
+func (s *StatusServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeStatus(w, http.StatusOK, s.Status())
+}
+
+// handleHealthz fails after several syncs in a row failed, so it can be used
+// as the health check of the sidecar container.
+func (s *StatusServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	status := s.Status()
+
+	if status.ConsecutiveFailures >= unhealthySyncFailures {
+		http.Error(w, status.LastError, http.StatusServiceUnavailable)
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
+
+func (s *StatusServer) handleAction(action func(ctx context.Context) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if s.isLeader != nil && !s.isLeader() {
+			http.Error(w, "this replica is not the leader", http.StatusConflict)
+			return
+		}
+
+		statusCode := http.StatusOK
+
+		err := action(r.Context())
+		if err != nil {
+			statusCode = http.StatusInternalServerError
+		}
+
+		writeStatus(w, statusCode, s.Status())
+	}
+}
+
+func writeStatus(w http.ResponseWriter, statusCode int, status sidecarStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	err := json.NewEncoder(w).Encode(status)
+	if err != nil {
+		log.Printf("Error writing the status: %v\n", err)
+	}
+}
+
+// ListenAndServe serves the status api until the context is done.
+func (s *StatusServer) ListenAndServe(ctx context.Context, addr string) error {
+	server := &http.Server{Addr: addr, Handler: s.Handler()}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), statusServerShutdownTimeout)
+		defer cancel()
+
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Serving the status api on '%v'\n", addr)
+
+	err := server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
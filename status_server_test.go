@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSyncTime = time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func newTestStatusServer(publishErr error, withdrawErr error) (*StatusServer, *[]string) {
+	calls := []string{}
+
+	status := sidecarStatus{
+		TaskArn:   "taskArn",
+		Eni:       "eni",
+		PublicIps: []string{"1.1.1.1"},
+		Records:   []publishedRecord{{Name: "domain", HostedZoneId: "zoneId", TTL: 300}},
+	}
+
+	statusServer := NewStatusServer(status, nil, nil, func(ctx context.Context) error {
+		calls = append(calls, "publish")
+		return publishErr
+	}, func(ctx context.Context) error {
+		calls = append(calls, "withdraw")
+		return withdrawErr
+	})
+	statusServer.now = func() time.Time {
+		return testSyncTime
+	}
+
+	return statusServer, &calls
+}
+
+func decodeStatus(t *testing.T, resp *http.Response) sidecarStatus {
+	status := sidecarStatus{}
+
+	err := json.NewDecoder(resp.Body).Decode(&status)
+	assert.Nil(t, err)
+
+	return status
+}
+
+func Test_StatusServer_Status(t *testing.T) {
+	statusServer, _ := newTestStatusServer(nil, nil)
+	server := httptest.NewServer(statusServer.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/status")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, sidecarStatus{
+		TaskArn:   "taskArn",
+		Eni:       "eni",
+		PublicIps: []string{"1.1.1.1"},
+		Records:   []publishedRecord{{Name: "domain", HostedZoneId: "zoneId", TTL: 300}},
+	}, decodeStatus(t, resp))
+}
+
+func Test_StatusServer_Status_MethodNotAllowed(t *testing.T) {
+	statusServer, _ := newTestStatusServer(nil, nil)
+	server := httptest.NewServer(statusServer.Handler())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/status", "application/json", nil)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func Test_StatusServer_Sync(t *testing.T) {
+	statusServer, calls := newTestStatusServer(nil, nil)
+	server := httptest.NewServer(statusServer.Handler())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/sync", "application/json", nil)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	status := decodeStatus(t, resp)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"publish"}, *calls)
+	assert.True(t, status.Records[0].Published)
+	assert.True(t, testSyncTime.Equal(*status.LastSync))
+	assert.Empty(t, status.LastError)
+}
+
+func Test_StatusServer_Sync_MethodNotAllowed(t *testing.T) {
+	statusServer, calls := newTestStatusServer(nil, nil)
+	server := httptest.NewServer(statusServer.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/sync")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Empty(t, *calls)
+}
+
+func Test_StatusServer_Withdraw(t *testing.T) {
+	statusServer, calls := newTestStatusServer(nil, nil)
+	server := httptest.NewServer(statusServer.Handler())
+	defer server.Close()
+
+	statusServer.Publish(context.TODO())
+
+	resp, err := http.Post(server.URL+"/withdraw", "application/json", nil)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	status := decodeStatus(t, resp)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"publish", "withdraw"}, *calls)
+	assert.False(t, status.Records[0].Published)
+}
+
+func Test_StatusServer_Healthz(t *testing.T) {
+	statusServer, _ := newTestStatusServer(fmt.Errorf("some error"), nil)
+	server := httptest.NewServer(statusServer.Handler())
+	defer server.Close()
+
+	for i := 1; i <= unhealthySyncFailures; i++ {
+		resp, err := http.Get(server.URL + "/healthz")
+		assert.Nil(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = http.Post(server.URL+"/sync", "application/json", nil)
+		assert.Nil(t, err)
+
+		status := decodeStatus(t, resp)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, "some error", status.LastError)
+		assert.Equal(t, i, status.ConsecutiveFailures)
+	}
+
+	resp, err := http.Get(server.URL + "/healthz")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "some error\n", string(body))
+}
+
+func Test_StatusServer_Sync_NotLeader(t *testing.T) {
+	statusServer, calls := newTestStatusServer(nil, nil)
+	statusServer.RequireLeader(func() bool {
+		return false
+	})
+	server := httptest.NewServer(statusServer.Handler())
+	defer server.Close()
+
+	for _, path := range []string{"/sync", "/withdraw"} {
+		resp, err := http.Post(server.URL+path, "application/json", nil)
+		assert.Nil(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	}
+
+	assert.Empty(t, *calls)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbv2Types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+const (
+	// defaultDeregistrationTimeout stays under the 120s maximum stopTimeout of
+	// the containers, so the rest of the shutdown can still run.
+	defaultDeregistrationTimeout = 90 * time.Second
+	targetDrainPollInterval      = 5 * time.Second
+
+	invalidTargetErrorCode = "InvalidTarget"
+)
+
+// TargetGroupRegistration registers the task ip in an ELBv2 target group the
+// ECS service doesn't manage, like a shared NLB in another account.
+type TargetGroupRegistration struct {
+	api              Elbv2Api
+	targetGroupArn   string
+	port             int32
+	availabilityZone string
+	pollInterval     time.Duration
+	sleep            func(ctx context.Context, d time.Duration) error
+}
+
+// NewTargetGroupRegistration receives the availability zone of the targets,
+// which must be "all" when the ip is outside the vpc of the target group.
+func NewTargetGroupRegistration(api Elbv2Api, targetGroupArn string, port int32, availabilityZone string) *TargetGroupRegistration {
+	return &TargetGroupRegistration{
+		api:              api,
+		targetGroupArn:   targetGroupArn,
+		port:             port,
+		availabilityZone: availabilityZone,
+		pollInterval:     targetDrainPollInterval,
+		sleep:            sleepContext,
+	}
+}
+
+func parseTargetPort(value string) (int32, error) {
+	if len(value) == 0 {
+		return 0, fmt.Errorf("the target group needs a target port")
+	}
+
+	port, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("invalid target port '%v'", value)
+	}
+
+	return int32(port), nil
+}
+
+func (r *TargetGroupRegistration) Register(ctx context.Context, ip string) error {
+	_, err := r.api.RegisterTargets(ctx, &elasticloadbalancingv2.RegisterTargetsInput{
+		TargetGroupArn: aws.String(r.targetGroupArn),
+		Targets:        []elbv2Types.TargetDescription{r.target(ip)},
+	})
+	if err != nil {
+		return fmt.Errorf("error registering '%v:%v' in target group '%v': %v", ip, r.port, r.targetGroupArn, err)
+	}
+
+	log.Printf("Registered '%v:%v' in target group '%v'\n", ip, r.port, r.targetGroupArn)
+
+	return nil
+}
+
+// Deregister removes the target and waits, up to the timeout, until the load
+// balancer has drained its connections.
+func (r *TargetGroupRegistration) Deregister(ctx context.Context, ip string, timeout time.Duration) error {
+	_, err := r.api.DeregisterTargets(ctx, &elasticloadbalancingv2.DeregisterTargetsInput{
+		TargetGroupArn: aws.String(r.targetGroupArn),
+		Targets:        []elbv2Types.TargetDescription{r.target(ip)},
+	})
+	if err != nil {
+		return fmt.Errorf("error deregistering '%v:%v' from target group '%v': %v", ip, r.port, r.targetGroupArn, err)
+	}
+
+	log.Printf("Deregistered '%v:%v' from target group '%v', waiting for draining\n", ip, r.port, r.targetGroupArn)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		drained, err := r.drained(ctx, ip)
+		if err != nil {
+			return err
+		}
+
+		if drained {
+			log.Printf("Target '%v:%v' drained\n", ip, r.port)
+
+			return nil
+		}
+
+		err = r.sleep(ctx, r.pollInterval)
+		if err != nil {
+			return fmt.Errorf("target '%v:%v' didn't drain from target group '%v' in %v", ip, r.port, r.targetGroupArn, timeout)
+		}
+	}
+}
+
+func (r *TargetGroupRegistration) drained(ctx context.Context, ip string) (bool, error) {
+	output, err := r.api.DescribeTargetHealth(ctx, &elasticloadbalancingv2.DescribeTargetHealthInput{
+		TargetGroupArn: aws.String(r.targetGroupArn),
+		Targets:        []elbv2Types.TargetDescription{r.target(ip)},
+	})
+	if awsErrorCode(err) == invalidTargetErrorCode {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error describing the health of '%v:%v' in target group '%v': %v", ip, r.port, r.targetGroupArn, err)
+	}
+
+	for _, description := range output.TargetHealthDescriptions {
+		if description.TargetHealth != nil && description.TargetHealth.State != elbv2Types.TargetHealthStateEnumUnused {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (r *TargetGroupRegistration) target(ip string) elbv2Types.TargetDescription {
+	target := elbv2Types.TargetDescription{Id: aws.String(ip), Port: aws.Int32(r.port)}
+	if len(r.availabilityZone) > 0 {
+		target.AvailabilityZone = aws.String(r.availabilityZone)
+	}
+
+	return target
+}
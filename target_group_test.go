@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbv2Types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestTargetGroupRegistration(elbv2Api Elbv2Api) *TargetGroupRegistration {
+	registration := NewTargetGroupRegistration(elbv2Api, "tg-arn", 8080, "all")
+	registration.sleep = func(ctx context.Context, d time.Duration) error {
+		return nil
+	}
+
+	return registration
+}
+
+func testTargets() []elbv2Types.TargetDescription {
+	return []elbv2Types.TargetDescription{{Id: aws.String("10.0.0.1"), Port: aws.Int32(8080), AvailabilityZone: aws.String("all")}}
+}
+
+func describeTargetHealth(mockedElbv2Api *MockedElbv2Api, state elbv2Types.TargetHealthStateEnum) {
+	input := &elasticloadbalancingv2.DescribeTargetHealthInput{TargetGroupArn: aws.String("tg-arn"), Targets: testTargets()}
+	output := &elasticloadbalancingv2.DescribeTargetHealthOutput{
+		TargetHealthDescriptions: []elbv2Types.TargetHealthDescription{{TargetHealth: &elbv2Types.TargetHealth{State: state}}},
+	}
+	mockedElbv2Api.On("DescribeTargetHealth", mock.Anything, input).Return(output, nil).Once()
+}
+
+func deregisterTargetsInput() *elasticloadbalancingv2.DeregisterTargetsInput {
+	return &elasticloadbalancingv2.DeregisterTargetsInput{TargetGroupArn: aws.String("tg-arn"), Targets: testTargets()}
+}
+
+func Test_TargetGroupRegistration_Register(t *testing.T) {
+	mockedElbv2Api := NewMockedElbv2Api()
+
+	input := &elasticloadbalancingv2.RegisterTargetsInput{TargetGroupArn: aws.String("tg-arn"), Targets: testTargets()}
+	mockedElbv2Api.On("RegisterTargets", context.TODO(), input).Return(&elasticloadbalancingv2.RegisterTargetsOutput{}, nil).Once()
+
+	err := newTestTargetGroupRegistration(mockedElbv2Api).Register(context.TODO(), "10.0.0.1")
+
+	assert.Nil(t, err)
+	mockedElbv2Api.AssertExpectations(t)
+}
+
+func Test_TargetGroupRegistration_Register_Error(t *testing.T) {
+	mockedElbv2Api := NewMockedElbv2Api()
+
+	mockedElbv2Api.On("RegisterTargets", context.TODO(), mock.Anything).Return(nil, fmt.Errorf("some error")).Once()
+
+	err := newTestTargetGroupRegistration(mockedElbv2Api).Register(context.TODO(), "10.0.0.1")
+
+	assert.EqualError(t, err, "error registering '10.0.0.1:8080' in target group 'tg-arn': some error")
+	mockedElbv2Api.AssertExpectations(t)
+}
+
+func Test_TargetGroupRegistration_Deregister_WaitsForDraining(t *testing.T) {
+	mockedElbv2Api := NewMockedElbv2Api()
+
+	mockedElbv2Api.On("DeregisterTargets", context.TODO(), deregisterTargetsInput()).Return(&elasticloadbalancingv2.DeregisterTargetsOutput{}, nil).Once()
+	describeTargetHealth(mockedElbv2Api, elbv2Types.TargetHealthStateEnumDraining)
+	describeTargetHealth(mockedElbv2Api, elbv2Types.TargetHealthStateEnumDraining)
+	describeTargetHealth(mockedElbv2Api, elbv2Types.TargetHealthStateEnumUnused)
+
+	err := newTestTargetGroupRegistration(mockedElbv2Api).Deregister(context.TODO(), "10.0.0.1", time.Minute)
+
+	assert.Nil(t, err)
+	mockedElbv2Api.AssertExpectations(t)
+}
+
+func Test_TargetGroupRegistration_Deregister_InvalidTarget(t *testing.T) {
+	mockedElbv2Api := NewMockedElbv2Api()
+
+	invalidTarget := &smithy.GenericAPIError{Code: "InvalidTarget", Message: "target is not registered"}
+	mockedElbv2Api.On("DeregisterTargets", context.TODO(), deregisterTargetsInput()).Return(&elasticloadbalancingv2.DeregisterTargetsOutput{}, nil).Once()
+	mockedElbv2Api.On("DescribeTargetHealth", mock.Anything, mock.Anything).Return(nil, invalidTarget).Once()
+
+	err := newTestTargetGroupRegistration(mockedElbv2Api).Deregister(context.TODO(), "10.0.0.1", time.Minute)
+
+	assert.Nil(t, err)
+	mockedElbv2Api.AssertExpectations(t)
+}
+
+func Test_TargetGroupRegistration_Deregister_Timeout(t *testing.T) {
+	mockedElbv2Api := NewMockedElbv2Api()
+
+	mockedElbv2Api.On("DeregisterTargets", context.TODO(), deregisterTargetsInput()).Return(&elasticloadbalancingv2.DeregisterTargetsOutput{}, nil).Once()
+	describeTargetHealth(mockedElbv2Api, elbv2Types.TargetHealthStateEnumDraining)
+
+	registration := newTestTargetGroupRegistration(mockedElbv2Api)
+	registration.sleep = func(ctx context.Context, d time.Duration) error {
+		return context.DeadlineExceeded
+	}
+
+	err := registration.Deregister(context.TODO(), "10.0.0.1", time.Minute)
+
+	assert.EqualError(t, err, "target '10.0.0.1:8080' didn't drain from target group 'tg-arn' in 1m0s")
+	mockedElbv2Api.AssertExpectations(t)
+}
+
+func Test_ParseTargetPort(t *testing.T) {
+	port, err := parseTargetPort("8080")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(8080), port)
+
+	_, err = parseTargetPort("")
+	assert.EqualError(t, err, "the target group needs a target port")
+
+	_, err = parseTargetPort("http")
+	assert.EqualError(t, err, "invalid target port 'http'")
+
+	_, err = parseTargetPort("0")
+	assert.EqualError(t, err, "invalid target port '0'")
+}
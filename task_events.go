@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+const (
+	taskStateChangeDetailType = "ECS Task State Change"
+	ecsEventSource            = "aws.ecs"
+
+	taskStatusRunning = "RUNNING"
+	taskStatusStopped = "STOPPED"
+
+	eventOutcomeIgnored   = "ignored"
+	eventOutcomeDuplicate = "duplicate"
+
+	defaultEventDeduplicationTTL = time.Hour
+)
+
+// taskStateChangeEvent is the EventBridge envelope of an ECS task state change.
+type taskStateChangeEvent struct {
+	Id         string                `json:"id"`
+	DetailType string                `json:"detail-type"`
+	Source     string                `json:"source"`
+	Time       time.Time             `json:"time"`
+	Detail     taskStateChangeDetail `json:"detail"`
+}
+
+type taskStateChangeDetail struct {
+	ClusterArn    string                `json:"clusterArn"`
+	TaskArn       string                `json:"taskArn"`
+	Group         string                `json:"group"`
+	LastStatus    string                `json:"lastStatus"`
+	DesiredStatus string                `json:"desiredStatus"`
+	Version       int64                 `json:"version"`
+	Attachments   []taskEventAttachment `json:"attachments"`
+}
+
+type taskEventAttachment struct {
+	Id      string              `json:"id"`
+	Type    string              `json:"type"`
+	Status  string              `json:"status"`
+	Details []taskEventKeyValue `json:"details"`
+}
+
+type taskEventKeyValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// eni returns the network interface attached to the task, when the event includes it.
+func (d taskStateChangeDetail) eni() (string, bool) {
+	for _, attachment := range d.Attachments {
+		if attachment.Type != "eni" {
+			continue
+		}
+
+		for _, detail := range attachment.Details {
+			if detail.Name == "networkInterfaceId" {
+				return detail.Value, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// TaskEventHandler publishes the ip of the tasks that reach RUNNING and
+// withdraws it when they reach STOPPED.
+type TaskEventHandler struct {
+	ecsApi         EcsApi
+	ec2Api         Ec2Api
+	route53Api     Route53Api
+	clusterName    string
+	useServiceTags bool
+	defaults       dnsSettings
+	deduplicator   *eventDeduplicator
+	now            func() time.Time
+}
+
+func NewTaskEventHandler(ecsApi EcsApi, ec2Api Ec2Api, route53Api Route53Api, clusterName string, useServiceTags bool, defaults dnsSettings) *TaskEventHandler {
+	return &TaskEventHandler{
+		ecsApi:         ecsApi,
+		ec2Api:         ec2Api,
+		route53Api:     route53Api,
+		clusterName:    clusterName,
+		useServiceTags: useServiceTags,
+		defaults:       defaults,
+		deduplicator:   newEventDeduplicator(defaultEventDeduplicationTTL),
+		now:            time.Now,
+	}
+}
+
+func (h *TaskEventHandler) Handle(ctx context.Context, event taskStateChangeEvent) (string, error) {
+	if event.Source != ecsEventSource || event.DetailType != taskStateChangeDetailType {
+		return eventOutcomeIgnored, nil
+	}
+
+	if len(h.clusterName) > 0 && !isSameCluster(event.Detail.ClusterArn, h.clusterName) {
+		return eventOutcomeIgnored, nil
+	}
+
+	if event.Detail.LastStatus != taskStatusRunning && event.Detail.LastStatus != taskStatusStopped {
+		return eventOutcomeIgnored, nil
+	}
+
+	if h.deduplicator.seen(event.Detail.TaskArn, event.Detail.Version, h.now()) {
+		log.Printf("Skipping event '%v': task '%v' version %v already handled\n", event.Id, event.Detail.TaskArn, event.Detail.Version)
+
+		return eventOutcomeDuplicate, nil
+	}
+
+	var outcome string
+	var err error
+
+	if event.Detail.LastStatus == taskStatusRunning {
+		outcome, err = h.publishTask(ctx, event.Detail)
+	} else {
+		outcome, err = h.withdrawTask(ctx, event.Detail)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	h.deduplicator.remember(event.Detail.TaskArn, event.Detail.Version, h.now())
+
+	log.Printf("Task '%v' %v: %v\n", event.Detail.TaskArn, strings.ToLower(event.Detail.LastStatus), outcome)
+
+	return outcome, nil
+}
+
+func (h *TaskEventHandler) publishTask(ctx context.Context, detail taskStateChangeDetail) (string, error) {
+	clusterName := clusterNameFromArn(detail.ClusterArn)
+
+	task, err := getTask(ctx, h.ecsApi, clusterName, detail.TaskArn)
+	if err != nil {
+		return "", err
+	}
+
+	eni, ok := detail.eni()
+	if !ok {
+		eni, err = getTaskEni(task)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	settings, err := getDnsSettings(ctx, h.ecsApi, clusterName, task, h.useServiceTags, h.defaults)
+	if err != nil {
+		return "", err
+	}
+	settings.Owner = recordOwnerId(ownerKindTask, clusterName)
+
+	publicIp, err := getPublicIpFromTaskEni(ctx, h.ec2Api, eni)
+	if err != nil {
+		return "", err
+	}
+
+	settings.HostedZoneId, err = getHostedZoneId(ctx, h.route53Api, settings)
+	if err != nil {
+		return "", err
+	}
+
+	outcome, err := addIpToRoute53RecordSet(ctx, h.route53Api, settings, publicIp)
+	if err != nil {
+		return "", err
+	}
+
+	err = tagPublishedResources(ctx, h.ecsApi, h.ec2Api, detail.TaskArn, eni, settings, publicIp, h.now())
+	if err != nil {
+		log.Printf("Error tagging published resources: %v\n", err)
+	}
+
+	return outcome, nil
+}
+
+// withdrawTask reads what was published for a stopped task from the ownership
+// tags, because its eni and public ip are already gone.
+func (h *TaskEventHandler) withdrawTask(ctx context.Context, detail taskStateChangeDetail) (string, error) {
+	clusterName := clusterNameFromArn(detail.ClusterArn)
+
+	task, err := getTask(ctx, h.ecsApi, clusterName, detail.TaskArn)
+	if err != nil {
+		return "", err
+	}
+
+	settings, publicIp, ok := getPublishedFromTags(task.Tags)
+	if !ok {
+		log.Printf("Task '%v' has no published dns name, nothing to withdraw\n", detail.TaskArn)
+
+		return publishOutcomeUnchanged, nil
+	}
+	settings.Owner = recordOwnerId(ownerKindTask, clusterName)
+
+	return withdrawIpFromRoute53RecordSet(ctx, h.route53Api, settings, publicIp)
+}
+
+func getPublishedFromTags(tags []ecsTypes.Tag) (dnsSettings, string, bool) {
+	settings := dnsSettings{}
+	publicIp := ""
+
+	for _, tag := range tags {
+		if tag.Key == nil || tag.Value == nil {
+			continue
+		}
+
+		switch *tag.Key {
+		case dnsNameTagKey:
+			settings.Domain = *tag.Value
+		case zoneIdTagKey:
+			settings.HostedZoneId = *tag.Value
+		case publicIpTagKey:
+			publicIp = *tag.Value
+		}
+	}
+
+	ok := len(settings.Domain) > 0 && len(settings.HostedZoneId) > 0 && len(publicIp) > 0
+
+	return settings, publicIp, ok
+}
+
+func clusterNameFromArn(clusterArn string) string {
+	return clusterArn[strings.LastIndex(clusterArn, "/")+1:]
+}
+
+// clusterKey is the name of the cluster, from its name or arn, that marks what
+// the sidecar owns in a cluster. The default cluster is used when it's empty.
+func clusterKey(clusterName string) string {
+	if len(clusterName) == 0 {
+		return "default"
+	}
+
+	return clusterNameFromArn(clusterName)
+}
+
+func isSameCluster(clusterArn string, clusterName string) bool {
+	return clusterArn == clusterName || clusterNameFromArn(clusterArn) == clusterName
+}
+
+// eventDeduplicator remembers the last handled version of each task, so
+// redelivered or out of order events are skipped.
+type eventDeduplicator struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	versions map[string]handledVersion
+}
+
+type handledVersion struct {
+	version   int64
+	handledAt time.Time
+}
+
+func newEventDeduplicator(ttl time.Duration) *eventDeduplicator {
+	return &eventDeduplicator{
+		ttl:      ttl,
+		versions: map[string]handledVersion{},
+	}
+}
+
+func (d *eventDeduplicator) seen(taskArn string, version int64, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for arn, handled := range d.versions {
+		if now.Sub(handled.handledAt) > d.ttl {
+			delete(d.versions, arn)
+		}
+	}
+
+	handled, ok := d.versions[taskArn]
+
+	return ok && version <= handled.version
+}
+
+func (d *eventDeduplicator) remember(taskArn string, version int64, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.versions[taskArn] = handledVersion{version: version, handledAt: now}
+}
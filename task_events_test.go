@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func stoppedTaskEvent(clusterArn string, version int64) taskStateChangeEvent {
+	return taskStateChangeEvent{
+		Id:         "id",
+		Source:     ecsEventSource,
+		DetailType: taskStateChangeDetailType,
+		Detail: taskStateChangeDetail{
+			ClusterArn: clusterArn,
+			TaskArn:    "taskArn",
+			LastStatus: taskStatusStopped,
+			Version:    version,
+		},
+	}
+}
+
+func Test_TaskEventHandler_Handle_OtherEvent_Ignored(t *testing.T) {
+	handler := NewTaskEventHandler(NewMockedEcsApi(), NewMockedEc2Api(), NewMockedRoute53Api(), "cluster", false, dnsSettings{})
+
+	result, err := handler.Handle(context.TODO(), taskStateChangeEvent{Source: "aws.ec2"})
+
+	assert.Equal(t, eventOutcomeIgnored, result)
+	assert.Nil(t, err)
+}
+
+func Test_TaskEventHandler_Handle_OtherCluster_Ignored(t *testing.T) {
+	handler := NewTaskEventHandler(NewMockedEcsApi(), NewMockedEc2Api(), NewMockedRoute53Api(), "cluster", false, dnsSettings{})
+
+	result, err := handler.Handle(context.TODO(), stoppedTaskEvent("arn:aws:ecs:eu-west-1:111122223333:cluster/other", 1))
+
+	assert.Equal(t, eventOutcomeIgnored, result)
+	assert.Nil(t, err)
+}
+
+func Test_TaskEventHandler_Handle_StoppedWithoutOwnershipTags_ThenDuplicate(t *testing.T) {
+	ctx := context.TODO()
+	mockedEcsApi := NewMockedEcsApi()
+
+	describeTasksInput := &ecs.DescribeTasksInput{
+		Cluster: aws.String("cluster"),
+		Tasks:   []string{"taskArn"},
+		Include: []ecsTypes.TaskField{ecsTypes.TaskFieldTags},
+	}
+
+	mockedEcsApi.On("DescribeTasks", ctx, describeTasksInput).Return(&ecs.DescribeTasksOutput{Tasks: []ecsTypes.Task{{}}}, nil).Once()
+
+	handler := NewTaskEventHandler(mockedEcsApi, NewMockedEc2Api(), NewMockedRoute53Api(), "cluster", false, dnsSettings{})
+	event := stoppedTaskEvent("arn:aws:ecs:eu-west-1:111122223333:cluster/cluster", 2)
+
+	result, err := handler.Handle(ctx, event)
+
+	assert.Equal(t, publishOutcomeUnchanged, result)
+	assert.Nil(t, err)
+
+	result, err = handler.Handle(ctx, stoppedTaskEvent("arn:aws:ecs:eu-west-1:111122223333:cluster/cluster", 1))
+
+	assert.Equal(t, eventOutcomeDuplicate, result)
+	assert.Nil(t, err)
+
+	mockedEcsApi.AssertExpectations(t)
+}
+
+func Test_EventDeduplicator_ForgetsExpiredTasks(t *testing.T) {
+	now := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	deduplicator := newEventDeduplicator(time.Hour)
+
+	deduplicator.remember("taskArn", 3, now)
+
+	assert.True(t, deduplicator.seen("taskArn", 3, now.Add(time.Minute)))
+	assert.False(t, deduplicator.seen("taskArn", 4, now.Add(time.Minute)))
+	assert.False(t, deduplicator.seen("taskArn", 3, now.Add(2*time.Hour)))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName         = "github.com/AngelVlc/ecs-sidecar"
+	tracingServiceName = "ecs-sidecar"
+
+	taskArnAttributeKey = attribute.Key("ecs.task.arn")
+	domainAttributeKey  = attribute.Key("dns.domain")
+)
+
+// initTracing exports the spans through OTLP to the endpoint. Without an
+// endpoint tracing stays disabled. The returned function flushes the spans.
+func initTracing(ctx context.Context, endpoint string) (func(ctx context.Context) error, error) {
+	if len(endpoint) == 0 {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpoint(endpoint), otlptracegrpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("error creating the otlp exporter: %v", err)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(tracingServiceName))),
+	)
+
+	otel.SetTracerProvider(tracerProvider)
+
+	return tracerProvider.Shutdown, nil
+}
+
+func startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// endSpan ends the span, marking it as failed when there is an error.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// tracedAction runs the action in its own span.
+func tracedAction(name string, action func(ctx context.Context) error, attributes ...attribute.KeyValue) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ctx, span := startSpan(ctx, name, attributes...)
+
+		err := action(ctx)
+		endSpan(span, err)
+
+		return err
+	}
+}
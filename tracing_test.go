@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupTestTracing(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+
+	return exporter
+}
+
+func Test_InitTracing_Disabled(t *testing.T) {
+	shutdown, err := initTracing(context.TODO(), "")
+
+	assert.Nil(t, err)
+	assert.Nil(t, shutdown(context.TODO()))
+}
+
+func Test_TracedAction_NestsSpans(t *testing.T) {
+	exporter := setupTestTracing(t)
+
+	ctx, parent := startSpan(context.TODO(), "discover")
+
+	stepCtx, span := startSpan(ctx, metadataStep)
+	endSpan(span, nil)
+
+	publish := tracedAction("publish", func(ctx context.Context) error {
+		return nil
+	}, taskArnAttributeKey.String("taskArn"), domainAttributeKey.String("domain"))
+
+	err := publish(stepCtx)
+	parent.End()
+
+	assert.Nil(t, err)
+
+	spans := exporter.GetSpans()
+
+	assert.Equal(t, 3, len(spans))
+	assert.Equal(t, metadataStep, spans[0].Name)
+	assert.Equal(t, "publish", spans[1].Name)
+	assert.Equal(t, "discover", spans[2].Name)
+
+	assert.Equal(t, spans[2].SpanContext.SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, spans[0].SpanContext.SpanID(), spans[1].Parent.SpanID())
+	assert.Equal(t, spans[2].SpanContext.TraceID(), spans[1].SpanContext.TraceID())
+
+	assert.ElementsMatch(t, []interface{}{taskArnAttributeKey.String("taskArn"), domainAttributeKey.String("domain")}, []interface{}{spans[1].Attributes[0], spans[1].Attributes[1]})
+	assert.Equal(t, codes.Unset, spans[1].Status.Code)
+}
+
+func Test_TracedAction_Error(t *testing.T) {
+	exporter := setupTestTracing(t)
+
+	withdraw := tracedAction("withdraw", func(ctx context.Context) error {
+		return fmt.Errorf("some error")
+	})
+
+	err := withdraw(context.TODO())
+
+	assert.EqualError(t, err, "some error")
+
+	spans := exporter.GetSpans()
+
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "some error", spans[0].Status.Description)
+	assert.Equal(t, 1, len(spans[0].Events))
+}
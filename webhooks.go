@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+const (
+	webhookFormatJson  = "json"
+	webhookFormatSlack = "slack"
+
+	defaultWebhookTimeout     = 5 * time.Second
+	defaultWebhookMaxAttempts = 3
+	webhookRetryDelay         = time.Second
+
+	defaultSlackTemplate = `{{if eq .Type "failed"}}:warning: {{end}}{{.Type}} {{.Record}}{{if .OldIp}} {{.OldIp}} ->{{end}}{{if .NewIp}} {{.NewIp}}{{end}}{{if .Error}}: {{.Error}}{{end}} ({{.TaskArn}})`
+)
+
+type webhookConfig struct {
+	Url    string   `json:"url"`
+	Format string   `json:"format"`
+	Events []string `json:"events"`
+	// Template is a go template rendered with the event. For the json format
+	// it is the whole body, for slack the text of the message.
+	Template    string `json:"template"`
+	Timeout     string `json:"timeout"`
+	MaxAttempts int    `json:"maxAttempts"`
+}
+
+// Webhook posts the events it is subscribed to, retrying on network errors
+// and 5xx or 429 responses.
+type Webhook struct {
+	url         string
+	format      string
+	events      map[string]bool
+	template    *template.Template
+	client      *http.Client
+	maxAttempts int
+	retryDelay  time.Duration
+	sleep       func(ctx context.Context, d time.Duration) error
+}
+
+func NewWebhook(config webhookConfig) (*Webhook, error) {
+	if len(config.Url) == 0 {
+		return nil, fmt.Errorf("webhook without url")
+	}
+
+	w := &Webhook{
+		url:         config.Url,
+		format:      config.Format,
+		events:      map[string]bool{},
+		client:      &http.Client{Timeout: defaultWebhookTimeout},
+		maxAttempts: defaultWebhookMaxAttempts,
+		retryDelay:  webhookRetryDelay,
+		sleep:       sleepContext,
+	}
+
+	if len(w.format) == 0 {
+		w.format = webhookFormatJson
+	}
+
+	if w.format != webhookFormatJson && w.format != webhookFormatSlack {
+		return nil, fmt.Errorf("invalid format '%v' in webhook '%v'", config.Format, config.Url)
+	}
+
+	events := config.Events
+	if len(events) == 0 {
+		events = []string{eventPublished, eventChanged, eventWithdrawn, eventFailed}
+	}
+
+	for _, event := range events {
+		switch event {
+		case eventPublished, eventChanged, eventWithdrawn, eventFailed:
+			w.events[event] = true
+		default:
+			return nil, fmt.Errorf("invalid event '%v' in webhook '%v'", event, config.Url)
+		}
+	}
+
+	text := config.Template
+	if len(text) == 0 && w.format == webhookFormatSlack {
+		text = defaultSlackTemplate
+	}
+
+	if len(text) > 0 {
+		tmpl, err := template.New(config.Url).Funcs(template.FuncMap{"json": toJson}).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template in webhook '%v': %v", config.Url, err)
+		}
+
+		w.template = tmpl
+	}
+
+	if len(config.Timeout) > 0 {
+		timeout, err := time.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout in webhook '%v': %v", config.Url, err)
+		}
+
+		w.client.Timeout = timeout
+	}
+
+	if config.MaxAttempts > 0 {
+		w.maxAttempts = config.MaxAttempts
+	}
+
+	return w, nil
+}
+
+func loadWebhooks(path string) ([]*Webhook, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the webhooks config: %v", err)
+	}
+
+	configs := []webhookConfig{}
+
+	err = json.Unmarshal(content, &configs)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the webhooks config: %v", err)
+	}
+
+	webhooks := []*Webhook{}
+	for _, config := range configs {
+		webhook, err := NewWebhook(config)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+func (w *Webhook) Notify(ctx context.Context, event lifecycleEvent) error {
+	if !w.events[event.Type] {
+		return nil
+	}
+
+	body, err := w.body(event)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+
+		if !retry || attempt >= w.maxAttempts {
+			return fmt.Errorf("error calling the webhook '%v': %v", w.url, err)
+		}
+
+		err = w.sleep(ctx, w.retryDelay*time.Duration(attempt))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (w *Webhook) body(event lifecycleEvent) ([]byte, error) {
+	text := ""
+	if w.template != nil {
+		var buf bytes.Buffer
+
+		err := w.template.Execute(&buf, event)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering the template of the webhook '%v': %v", w.url, err)
+		}
+
+		text = buf.String()
+	}
+
+	switch {
+	case w.format == webhookFormatSlack:
+		return json.Marshal(map[string]string{"text": text})
+	case w.template != nil:
+		return []byte(text), nil
+	}
+
+	return json.Marshal(event)
+}
+
+// post returns whether a failed request is worth retrying.
+func (w *Webhook) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+
+	return retry, fmt.Errorf("unexpected status %v", resp.StatusCode)
+}
+
+func toJson(value interface{}) (string, error) {
+	content, err := json.Marshal(value)
+
+	return string(content), err
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testChangedEvent = lifecycleEvent{
+	Type:         eventChanged,
+	Time:         time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC),
+	TaskArn:      "taskArn",
+	Cluster:      "cluster",
+	Record:       "app.example.com",
+	HostedZoneId: "zoneId",
+	OldIp:        "1.1.1.1",
+	NewIp:        "2.2.2.2",
+}
+
+type webhookReceiver struct {
+	server   *httptest.Server
+	bodies   []string
+	statuses []int
+}
+
+func newWebhookReceiver(statuses ...int) *webhookReceiver {
+	r := &webhookReceiver{statuses: statuses}
+
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		r.bodies = append(r.bodies, string(body))
+
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status = r.statuses[0]
+			r.statuses = r.statuses[1:]
+		}
+
+		w.WriteHeader(status)
+	}))
+
+	return r
+}
+
+func newTestWebhook(t *testing.T, config webhookConfig) *Webhook {
+	webhook, err := NewWebhook(config)
+	assert.Nil(t, err)
+
+	webhook.sleep = func(ctx context.Context, d time.Duration) error {
+		return nil
+	}
+
+	return webhook
+}
+
+func Test_Webhook_Json(t *testing.T) {
+	receiver := newWebhookReceiver()
+	defer receiver.server.Close()
+
+	webhook := newTestWebhook(t, webhookConfig{Url: receiver.server.URL})
+
+	err := webhook.Notify(context.TODO(), testChangedEvent)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`{"type":"changed","time":"2022-03-01T10:00:00Z","taskArn":"taskArn","cluster":"cluster","record":"app.example.com","hostedZoneId":"zoneId","oldIp":"1.1.1.1","newIp":"2.2.2.2"}`}, receiver.bodies)
+}
+
+func Test_Webhook_JsonTemplate(t *testing.T) {
+	receiver := newWebhookReceiver()
+	defer receiver.server.Close()
+
+	webhook := newTestWebhook(t, webhookConfig{Url: receiver.server.URL, Template: `{"summary":{{json .Record}},"ip":{{json .NewIp}}}`})
+
+	err := webhook.Notify(context.TODO(), testChangedEvent)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`{"summary":"app.example.com","ip":"2.2.2.2"}`}, receiver.bodies)
+}
+
+func Test_Webhook_Slack(t *testing.T) {
+	receiver := newWebhookReceiver()
+	defer receiver.server.Close()
+
+	webhook := newTestWebhook(t, webhookConfig{Url: receiver.server.URL, Format: webhookFormatSlack})
+
+	err := webhook.Notify(context.TODO(), testChangedEvent)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`{"text":"changed app.example.com 1.1.1.1 -\u003e 2.2.2.2 (taskArn)"}`}, receiver.bodies)
+}
+
+func Test_Webhook_FiltersEvents(t *testing.T) {
+	receiver := newWebhookReceiver()
+	defer receiver.server.Close()
+
+	webhook := newTestWebhook(t, webhookConfig{Url: receiver.server.URL, Events: []string{eventFailed}})
+
+	err := webhook.Notify(context.TODO(), testChangedEvent)
+
+	assert.Nil(t, err)
+	assert.Empty(t, receiver.bodies)
+}
+
+func Test_Webhook_RetriesServerErrors(t *testing.T) {
+	receiver := newWebhookReceiver(http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	defer receiver.server.Close()
+
+	webhook := newTestWebhook(t, webhookConfig{Url: receiver.server.URL})
+
+	err := webhook.Notify(context.TODO(), testChangedEvent)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(receiver.bodies))
+}
+
+func Test_WebHook_GivesUp(t *testing.T) {
+	receiver := newWebhookReceiver(http.StatusBadGateway, http.StatusBadGateway)
+	defer receiver.server.Close()
+
+	webhook := newTestWebhook(t, webhookConfig{Url: receiver.server.URL, MaxAttempts: 2})
+
+	err := webhook.Notify(context.TODO(), testChangedEvent)
+
+	assert.EqualError(t, err, "error calling the webhook '"+receiver.server.URL+"': unexpected status 502")
+	assert.Equal(t, 2, len(receiver.bodies))
+}
+
+func Test_Webhook_DoesNotRetryClientErrors(t *testing.T) {
+	receiver := newWebhookReceiver(http.StatusBadRequest)
+	defer receiver.server.Close()
+
+	webhook := newTestWebhook(t, webhookConfig{Url: receiver.server.URL})
+
+	err := webhook.Notify(context.TODO(), testChangedEvent)
+
+	assert.EqualError(t, err, "error calling the webhook '"+receiver.server.URL+"': unexpected status 400")
+	assert.Equal(t, 1, len(receiver.bodies))
+}
+
+func Test_Webhook_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	webhook := newTestWebhook(t, webhookConfig{Url: server.URL, Timeout: "10ms", MaxAttempts: 1})
+
+	err := webhook.Notify(context.TODO(), testChangedEvent)
+
+	assert.NotNil(t, err)
+}
+
+func Test_NewWebhook_InvalidConfig(t *testing.T) {
+	_, err := NewWebhook(webhookConfig{})
+	assert.EqualError(t, err, "webhook without url")
+
+	_, err = NewWebhook(webhookConfig{Url: "http://hook", Format: "xml"})
+	assert.EqualError(t, err, "invalid format 'xml' in webhook 'http://hook'")
+
+	_, err = NewWebhook(webhookConfig{Url: "http://hook", Events: []string{"deleted"}})
+	assert.EqualError(t, err, "invalid event 'deleted' in webhook 'http://hook'")
+
+	_, err = NewWebhook(webhookConfig{Url: "http://hook", Template: "{{.Record"})
+	assert.Contains(t, err.Error(), "invalid template in webhook 'http://hook'")
+}
+
+func Test_LoadWebhooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	os.WriteFile(path, []byte(`[{"url":"http://hook","format":"slack","events":["failed"],"timeout":"2s","maxAttempts":5}]`), 0644)
+
+	webhooks, err := loadWebhooks(path)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(webhooks))
+	assert.Equal(t, map[string]bool{eventFailed: true}, webhooks[0].events)
+	assert.Equal(t, 2*time.Second, webhooks[0].client.Timeout)
+	assert.Equal(t, 5, webhooks[0].maxAttempts)
+}
+
+func Test_PublishEvent(t *testing.T) {
+	plan := recordPlan{OldIps: []string{"1.1.1.1"}, NewIps: []string{"2.2.2.2"}}
+
+	event, ok := publishEvent(publishOutcomeUpdated, plan, lifecycleEvent{Record: "app.example.com"})
+
+	assert.True(t, ok)
+	assert.Equal(t, lifecycleEvent{Type: eventChanged, Record: "app.example.com", OldIp: "1.1.1.1", NewIp: "2.2.2.2"}, event)
+
+	_, ok = publishEvent(publishOutcomeUnchanged, plan, lifecycleEvent{})
+
+	assert.False(t, ok)
+}
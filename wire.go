@@ -63,6 +63,78 @@ func initMockedRoute53Api() Route53Api {
 	return nil
 }
 
+func InitSqsApi(cfg aws.Config) SqsApi {
+	if inTestingMode() {
+		return initMockedSqsApi()
+	} else {
+		return initAwsSqsApi(cfg)
+	}
+}
+
+func initAwsSqsApi(cfg aws.Config) SqsApi {
+	wire.Build(AwsSqsApiSet)
+	return nil
+}
+
+func initMockedSqsApi() SqsApi {
+	wire.Build(MockedSqsApiSet)
+	return nil
+}
+
+func InitSnsApi(cfg aws.Config) SnsApi {
+	if inTestingMode() {
+		return initMockedSnsApi()
+	} else {
+		return initAwsSnsApi(cfg)
+	}
+}
+
+func initAwsSnsApi(cfg aws.Config) SnsApi {
+	wire.Build(AwsSnsApiSet)
+	return nil
+}
+
+func initMockedSnsApi() SnsApi {
+	wire.Build(MockedSnsApiSet)
+	return nil
+}
+
+func InitEventBridgeApi(cfg aws.Config) EventBridgeApi {
+	if inTestingMode() {
+		return initMockedEventBridgeApi()
+	} else {
+		return initAwsEventBridgeApi(cfg)
+	}
+}
+
+func initAwsEventBridgeApi(cfg aws.Config) EventBridgeApi {
+	wire.Build(AwsEventBridgeApiSet)
+	return nil
+}
+
+func initMockedEventBridgeApi() EventBridgeApi {
+	wire.Build(MockedEventBridgeApiSet)
+	return nil
+}
+
+func InitElbv2Api(cfg aws.Config) Elbv2Api {
+	if inTestingMode() {
+		return initMockedElbv2Api()
+	} else {
+		return initAwsElbv2Api(cfg)
+	}
+}
+
+func initAwsElbv2Api(cfg aws.Config) Elbv2Api {
+	wire.Build(AwsElbv2ApiSet)
+	return nil
+}
+
+func initMockedElbv2Api() Elbv2Api {
+	wire.Build(MockedElbv2ApiSet)
+	return nil
+}
+
 func InitMetadataEndpointClient() MetadataEndpointClient {
 	if inTestingMode() {
 		return initMockedMetadataEndpointClient()
@@ -115,6 +187,46 @@ var AwsRoute53ApiSet = wire.NewSet(
 	wire.Bind(new(Route53Api), new(*AwsRoute53Api)),
 )
 
+var MockedSqsApiSet = wire.NewSet(
+	NewMockedSqsApi,
+	wire.Bind(new(SqsApi), new(*MockedSqsApi)),
+)
+
+var AwsSqsApiSet = wire.NewSet(
+	NewAwsSqsApi,
+	wire.Bind(new(SqsApi), new(*AwsSqsApi)),
+)
+
+var MockedSnsApiSet = wire.NewSet(
+	NewMockedSnsApi,
+	wire.Bind(new(SnsApi), new(*MockedSnsApi)),
+)
+
+var AwsSnsApiSet = wire.NewSet(
+	NewAwsSnsApi,
+	wire.Bind(new(SnsApi), new(*AwsSnsApi)),
+)
+
+var MockedEventBridgeApiSet = wire.NewSet(
+	NewMockedEventBridgeApi,
+	wire.Bind(new(EventBridgeApi), new(*MockedEventBridgeApi)),
+)
+
+var AwsEventBridgeApiSet = wire.NewSet(
+	NewAwsEventBridgeApi,
+	wire.Bind(new(EventBridgeApi), new(*AwsEventBridgeApi)),
+)
+
+var MockedElbv2ApiSet = wire.NewSet(
+	NewMockedElbv2Api,
+	wire.Bind(new(Elbv2Api), new(*MockedElbv2Api)),
+)
+
+var AwsElbv2ApiSet = wire.NewSet(
+	NewAwsElbv2Api,
+	wire.Bind(new(Elbv2Api), new(*AwsElbv2Api)),
+)
+
 var MockedMetadataEndpointClientSet = wire.NewSet(
 	NewMockedMetadataEndpointClient,
 	wire.Bind(new(MetadataEndpointClient), new(*MockedMetadataEndpointClient)),
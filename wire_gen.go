@@ -43,6 +43,46 @@ func initMockedRoute53Api() Route53Api {
 	return mockedRoute53Api
 }
 
+func initAwsSqsApi(cfg aws.Config) SqsApi {
+	awsSqsApi := NewAwsSqsApi(cfg)
+	return awsSqsApi
+}
+
+func initMockedSqsApi() SqsApi {
+	mockedSqsApi := NewMockedSqsApi()
+	return mockedSqsApi
+}
+
+func initAwsSnsApi(cfg aws.Config) SnsApi {
+	awsSnsApi := NewAwsSnsApi(cfg)
+	return awsSnsApi
+}
+
+func initMockedSnsApi() SnsApi {
+	mockedSnsApi := NewMockedSnsApi()
+	return mockedSnsApi
+}
+
+func initAwsEventBridgeApi(cfg aws.Config) EventBridgeApi {
+	awsEventBridgeApi := NewAwsEventBridgeApi(cfg)
+	return awsEventBridgeApi
+}
+
+func initMockedEventBridgeApi() EventBridgeApi {
+	mockedEventBridgeApi := NewMockedEventBridgeApi()
+	return mockedEventBridgeApi
+}
+
+func initAwsElbv2Api(cfg aws.Config) Elbv2Api {
+	awsElbv2Api := NewAwsElbv2Api(cfg)
+	return awsElbv2Api
+}
+
+func initMockedElbv2Api() Elbv2Api {
+	mockedElbv2Api := NewMockedElbv2Api()
+	return mockedElbv2Api
+}
+
 func initRealMetadataEndpointClient() MetadataEndpointClient {
 	realMetadataEndpointClient := NewRealMetadataEndpointClient()
 	return realMetadataEndpointClient
@@ -79,6 +119,38 @@ func InitRoute53Api(cfg aws.Config) Route53Api {
 	}
 }
 
+func InitSqsApi(cfg aws.Config) SqsApi {
+	if inTestingMode() {
+		return initMockedSqsApi()
+	} else {
+		return initAwsSqsApi(cfg)
+	}
+}
+
+func InitSnsApi(cfg aws.Config) SnsApi {
+	if inTestingMode() {
+		return initMockedSnsApi()
+	} else {
+		return initAwsSnsApi(cfg)
+	}
+}
+
+func InitEventBridgeApi(cfg aws.Config) EventBridgeApi {
+	if inTestingMode() {
+		return initMockedEventBridgeApi()
+	} else {
+		return initAwsEventBridgeApi(cfg)
+	}
+}
+
+func InitElbv2Api(cfg aws.Config) Elbv2Api {
+	if inTestingMode() {
+		return initMockedElbv2Api()
+	} else {
+		return initAwsElbv2Api(cfg)
+	}
+}
+
 func InitMetadataEndpointClient() MetadataEndpointClient {
 	if inTestingMode() {
 		return initMockedMetadataEndpointClient()
@@ -115,6 +187,38 @@ var AwsRoute53ApiSet = wire.NewSet(
 	NewAwsRoute53Api, wire.Bind(new(Route53Api), new(*AwsRoute53Api)),
 )
 
+var MockedSqsApiSet = wire.NewSet(
+	NewMockedSqsApi, wire.Bind(new(SqsApi), new(*MockedSqsApi)),
+)
+
+var AwsSqsApiSet = wire.NewSet(
+	NewAwsSqsApi, wire.Bind(new(SqsApi), new(*AwsSqsApi)),
+)
+
+var MockedSnsApiSet = wire.NewSet(
+	NewMockedSnsApi, wire.Bind(new(SnsApi), new(*MockedSnsApi)),
+)
+
+var AwsSnsApiSet = wire.NewSet(
+	NewAwsSnsApi, wire.Bind(new(SnsApi), new(*AwsSnsApi)),
+)
+
+var MockedEventBridgeApiSet = wire.NewSet(
+	NewMockedEventBridgeApi, wire.Bind(new(EventBridgeApi), new(*MockedEventBridgeApi)),
+)
+
+var AwsEventBridgeApiSet = wire.NewSet(
+	NewAwsEventBridgeApi, wire.Bind(new(EventBridgeApi), new(*AwsEventBridgeApi)),
+)
+
+var MockedElbv2ApiSet = wire.NewSet(
+	NewMockedElbv2Api, wire.Bind(new(Elbv2Api), new(*MockedElbv2Api)),
+)
+
+var AwsElbv2ApiSet = wire.NewSet(
+	NewAwsElbv2Api, wire.Bind(new(Elbv2Api), new(*AwsElbv2Api)),
+)
+
 var MockedMetadataEndpointClientSet = wire.NewSet(
 	NewMockedMetadataEndpointClient, wire.Bind(new(MetadataEndpointClient), new(*MockedMetadataEndpointClient)),
 )